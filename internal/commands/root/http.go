@@ -17,18 +17,24 @@ package root
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"github.com/VineethReddy02/mocklet/internal/provider"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // AcceptedCiphers is the list of accepted TLS ciphers, with known weak ciphers elided
@@ -99,6 +105,12 @@ func setupHTTPServer(ctx context.Context, p provider.Provider, cfg *apiServerCon
 		}
 
 		api.AttachPodRoutes(podRoutes, mux, true)
+		if lp, ok := p.(provider.ContainerLogsProvider); ok {
+			attachContainerLogRoutes(lp, mux)
+		}
+		if cp, ok := p.(provider.NodeConditionsProvider); ok {
+			attachNodeConditionRoutes(cp, mux, false)
+		}
 
 		s := &http.Server{
 			Handler:   mux,
@@ -133,9 +145,216 @@ func setupHTTPServer(ctx context.Context, p provider.Provider, cfg *apiServerCon
 		closers = append(closers, s)
 	}
 
+	// The routes that change the node are only served on the debug listener, which is
+	// opt-in and bound to a loopback address since it is not authenticated.
+	if cfg.DebugAddr != "" {
+		l, err := net.Listen("tcp", cfg.DebugAddr)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not setup listener for debug http server")
+		}
+
+		mux := http.NewServeMux()
+		if cp, ok := p.(provider.NodeConditionsProvider); ok {
+			attachNodeConditionRoutes(cp, mux, true)
+		}
+		if sp, ok := p.(provider.NodeStatusProvider); ok {
+			attachNodeStatusRoutes(sp, mux)
+		}
+		s := &http.Server{
+			Handler: mux,
+		}
+		go serveHTTP(ctx, s, l, "debug")
+		closers = append(closers, s)
+	}
+
 	return cancel, nil
 }
 
+// attachContainerLogRoutes serves the logs of containers at
+// /containerLogs/{namespace}/{pod}/{container} with every option of the kubelet API, which
+// the pod routes only partly support: tailLines, limitBytes, timestamps, follow, previous,
+// sinceSeconds and sinceTime.
+func attachContainerLogRoutes(p provider.ContainerLogsProvider, mux *http.ServeMux) {
+	mux.HandleFunc("/containerLogs/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/containerLogs/"), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		opts, err := parseContainerLogOpts(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logs, err := p.GetContainerLogsWithOpts(r.Context(), parts[0], parts[1], parts[2], opts)
+		if err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errdefs.IsNotFound(err):
+				code = http.StatusNotFound
+			case errdefs.IsInvalidInput(err):
+				code = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		defer logs.Close()
+
+		w.Header().Set("Content-Type", "text/plain")
+		flusher, _ := w.(http.Flusher)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := logs.Read(buf)
+			if n > 0 {
+				if _, err := w.Write(buf[:n]); err != nil {
+					return
+				}
+				if flusher != nil && opts.Follow {
+					flusher.Flush()
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					log.G(r.Context()).WithError(err).Error("Error reading container logs")
+				}
+				return
+			}
+		}
+	})
+}
+
+// parseContainerLogOpts parses the options of a container logs request the way the
+// kubelet does. Without tailLines every line is returned.
+func parseContainerLogOpts(q url.Values) (provider.ContainerLogOpts, error) {
+	opts := provider.ContainerLogOpts{Tail: -1}
+	ints := map[string]*int{
+		"tailLines":    &opts.Tail,
+		"limitBytes":   &opts.LimitBytes,
+		"sinceSeconds": &opts.SinceSeconds,
+	}
+	for name, v := range ints {
+		if s := q.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 || (n == 0 && name != "tailLines") {
+				return opts, fmt.Errorf("invalid %s %q", name, s)
+			}
+			*v = n
+		}
+	}
+	bools := map[string]*bool{
+		"timestamps": &opts.Timestamps,
+		"follow":     &opts.Follow,
+		"previous":   &opts.Previous,
+	}
+	for name, v := range bools {
+		if s := q.Get(name); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s %q", name, s)
+			}
+			*v = b
+		}
+	}
+	if s := q.Get("sinceTime"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return opts, fmt.Errorf("invalid sinceTime %q", s)
+		}
+		opts.SinceTime = t
+	}
+	if opts.SinceSeconds > 0 && !opts.SinceTime.IsZero() {
+		return opts, fmt.Errorf("at most one of sinceSeconds or sinceTime may be specified")
+	}
+	return opts, nil
+}
+
+// attachNodeConditionRoutes serves the node conditions at /conditions: GET lists them and,
+// if writable, PUT sets the condition in the request body, e.g.
+// {"type": "KernelDeadlock", "status": "True"}.
+func attachNodeConditionRoutes(p provider.NodeConditionsProvider, mux *http.ServeMux, writable bool) {
+	allow := "GET"
+	if writable {
+		allow = "GET, PUT"
+	}
+	mux.HandleFunc("/conditions", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+		case r.Method == http.MethodPut && writable:
+			var condition corev1.NodeCondition
+			if err := json.NewDecoder(r.Body).Decode(&condition); err != nil {
+				http.Error(w, fmt.Sprintf("invalid node condition: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := p.SetNodeCondition(r.Context(), condition); err != nil {
+				code := http.StatusInternalServerError
+				if errdefs.IsInvalidInput(err) {
+					code = http.StatusBadRequest
+				}
+				http.Error(w, err.Error(), code)
+				return
+			}
+		default:
+			w.Header().Set("Allow", allow)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p.GetNodeConditions(r.Context())); err != nil {
+			log.G(r.Context()).WithError(err).Error("Error writing node conditions")
+		}
+	})
+}
+
+// attachNodeStatusRoutes lets the node be changed at runtime: PUT /resources sets the
+// resources in the request body, e.g. {"capacity": {"memory": "64Gi"}, "allocatable": {"memory": "60Gi"}},
+// and PUT /ping makes the kubelet unhealthy with {"error": "PLEG is not healthy"}, or
+// healthy again with {"error": ""}.
+func attachNodeStatusRoutes(p provider.NodeStatusProvider, mux *http.ServeMux) {
+	mux.HandleFunc("/resources", func(w http.ResponseWriter, r *http.Request) {
+		var resources struct {
+			Capacity    corev1.ResourceList `json:"capacity"`
+			Allocatable corev1.ResourceList `json:"allocatable"`
+		}
+		if !decodePut(w, r, &resources) {
+			return
+		}
+		if err := p.SetNodeResources(r.Context(), resources.Capacity, resources.Allocatable); err != nil {
+			code := http.StatusInternalServerError
+			if errdefs.IsInvalidInput(err) {
+				code = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		var ping struct {
+			Error string `json:"error"`
+		}
+		if !decodePut(w, r, &ping) {
+			return
+		}
+		p.SetPingFailure(r.Context(), ping.Error)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// decodePut decodes the body of a PUT request into v, and replies with an error and
+// returns false if the request is not one.
+func decodePut(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPut {
+		w.Header().Set("Allow", "PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 func serveHTTP(ctx context.Context, s *http.Server, l net.Listener, name string) {
 	if err := s.Serve(l); err != nil {
 		select {
@@ -152,6 +371,7 @@ type apiServerConfig struct {
 	KeyPath               string
 	Addr                  string
 	MetricsAddr           string
+	DebugAddr             string
 	StreamIdleTimeout     time.Duration
 	StreamCreationTimeout time.Duration
 }
@@ -164,8 +384,29 @@ func getAPIConfig(c Opts) (*apiServerConfig, error) {
 
 	config.Addr = fmt.Sprintf(":%d", c.ListenPort)
 	config.MetricsAddr = c.MetricsAddr
+	if c.DebugAddr != "" {
+		if err := checkLoopback(c.DebugAddr); err != nil {
+			return nil, errors.Wrap(err, "invalid debug address")
+		}
+		config.DebugAddr = c.DebugAddr
+	}
 	config.StreamIdleTimeout = c.StreamIdleTimeout
 	config.StreamCreationTimeout = c.StreamCreationTimeout
 
 	return &config, nil
 }
+
+// checkLoopback checks that addr only listens on a loopback interface.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s is not a loopback address", addr)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright © 2017 The mocklet authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package root
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VineethReddy02/mocklet/internal/provider"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type fakeConditionsProvider struct {
+	conditions []corev1.NodeCondition
+}
+
+func (p *fakeConditionsProvider) GetNodeConditions(context.Context) []corev1.NodeCondition {
+	return p.conditions
+}
+
+func (p *fakeConditionsProvider) SetNodeCondition(_ context.Context, c corev1.NodeCondition) error {
+	if c.Status == "" {
+		return errdefs.InvalidInput("missing status")
+	}
+	p.conditions = append(p.conditions, c)
+	return nil
+}
+
+func TestNodeConditionRoutes(t *testing.T) {
+	p := &fakeConditionsProvider{}
+	// The kubelet port only serves the conditions.
+	readOnly := http.NewServeMux()
+	attachNodeConditionRoutes(p, readOnly, false)
+	w := httptest.NewRecorder()
+	readOnly.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/conditions", strings.NewReader(`{"type": "KernelDeadlock", "status": "True"}`)))
+	if w.Code != http.StatusMethodNotAllowed || len(p.conditions) != 0 {
+		t.Fatalf("expected conditions to be read-only, got status %d", w.Code)
+	}
+
+	mux := http.NewServeMux()
+	attachNodeConditionRoutes(p, mux, true)
+
+	for _, tc := range []struct {
+		method, body string
+		code         int
+	}{
+		{http.MethodPut, `{"type": "KernelDeadlock", "status": "True"}`, http.StatusOK},
+		{http.MethodPut, `{"type": "KernelDeadlock"}`, http.StatusBadRequest},
+		{http.MethodPut, `{`, http.StatusBadRequest},
+		{http.MethodDelete, ``, http.StatusMethodNotAllowed},
+		{http.MethodGet, ``, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tc.method, "/conditions", strings.NewReader(tc.body)))
+		if w.Code != tc.code {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", tc.method, tc.body, tc.code, w.Code, w.Body)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var conditions []corev1.NodeCondition
+		if err := json.NewDecoder(w.Body).Decode(&conditions); err != nil {
+			t.Fatal(err)
+		}
+		if len(conditions) != 1 || conditions[0].Type != "KernelDeadlock" {
+			t.Fatalf("expected the KernelDeadlock condition, got %v", conditions)
+		}
+	}
+}
+
+func TestCheckLoopback(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:10260": true,
+		"[::1]:10260":     true,
+		"localhost:10260": true,
+		":10260":          false,
+		"0.0.0.0:10260":   false,
+		"10.0.0.1:10260":  false,
+		"127.0.0.1":       false,
+	} {
+		if err := checkLoopback(addr); (err == nil) != ok {
+			t.Fatalf("%s: expected ok=%v, got %v", addr, ok, err)
+		}
+	}
+}
+
+type fakeStatusProvider struct {
+	capacity, allocatable corev1.ResourceList
+	pingFailure           string
+}
+
+func (p *fakeStatusProvider) SetNodeResources(_ context.Context, capacity, allocatable corev1.ResourceList) error {
+	p.capacity, p.allocatable = capacity, allocatable
+	return nil
+}
+
+func (p *fakeStatusProvider) SetPingFailure(_ context.Context, message string) {
+	p.pingFailure = message
+}
+
+func TestNodeStatusRoutes(t *testing.T) {
+	p := &fakeStatusProvider{}
+	mux := http.NewServeMux()
+	attachNodeStatusRoutes(p, mux)
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodPut, "/resources", `{"capacity": {"memory": "64Gi"}, "allocatable": {"memory": "60Gi"}}`, http.StatusNoContent},
+		{http.MethodPut, "/ping", `{"error": "PLEG is not healthy"}`, http.StatusNoContent},
+		{http.MethodPut, "/resources", `{"capacity": {"memory": "lots"}}`, http.StatusBadRequest},
+		{http.MethodGet, "/ping", ``, http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if w.Code != tc.code {
+			t.Fatalf("%s %s %s: expected status %d, got %d: %s", tc.method, tc.path, tc.body, tc.code, w.Code, w.Body)
+		}
+	}
+	if p.allocatable.Memory().String() != "60Gi" || p.pingFailure != "PLEG is not healthy" {
+		t.Fatalf("expected the resources and the ping failure to be set, got %v and %q", p.allocatable, p.pingFailure)
+	}
+}
+
+type fakeLogsProvider struct {
+	opts provider.ContainerLogOpts
+}
+
+func (p *fakeLogsProvider) GetContainerLogsWithOpts(_ context.Context, namespace, pod, container string, opts provider.ContainerLogOpts) (io.ReadCloser, error) {
+	if container != "app" {
+		return nil, errdefs.NotFound("container not found")
+	}
+	p.opts = opts
+	return ioutil.NopCloser(strings.NewReader(namespace + "/" + pod + "\n")), nil
+}
+
+func TestContainerLogRoutes(t *testing.T) {
+	p := &fakeLogsProvider{}
+	mux := http.NewServeMux()
+	attachContainerLogRoutes(p, mux)
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/containerLogs/default/web/app?tailLines=10&limitBytes=100&timestamps=true&follow=1&previous=true&sinceSeconds=60", http.StatusOK},
+		{"/containerLogs/default/web/db", http.StatusNotFound},
+		{"/containerLogs/default/web", http.StatusNotFound},
+		{"/containerLogs/default/web/app?tailLines=-1", http.StatusBadRequest},
+		{"/containerLogs/default/web/app?sinceSeconds=60&sinceTime=2020-01-01T00:00:00Z", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected status %d, got %d: %s", tc.path, tc.code, w.Code, w.Body)
+		}
+		if tc.code == http.StatusOK && w.Body.String() != "default/web\n" {
+			t.Fatalf("%s: unexpected logs %q", tc.path, w.Body)
+		}
+	}
+	want := provider.ContainerLogOpts{Tail: 10, LimitBytes: 100, Timestamps: true, Follow: true, Previous: true, SinceSeconds: 60}
+	if p.opts != want {
+		t.Fatalf("expected options %+v, got %+v", want, p.opts)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/containerLogs/default/web/app?sinceTime=2020-01-01T00:00:00Z", nil))
+	if p.opts.Tail != -1 || p.opts.SinceTime.IsZero() {
+		t.Fatalf("expected every line since the given time, got %+v", p.opts)
+	}
+}
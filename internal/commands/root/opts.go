@@ -72,6 +72,9 @@ type Opts struct {
 	DisableTaint bool
 
 	MetricsAddr string
+	// DebugAddr is the loopback address the routes that change the node at runtime are
+	// served on. They are not served when it is empty.
+	DebugAddr string
 
 	// Number of workers to use to handle pod notifications
 	PodSyncWorkers       int
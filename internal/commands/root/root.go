@@ -18,6 +18,7 @@ import (
 	"context"
 	"github.com/VineethReddy02/mocklet/internal/provider"
 	"github.com/VineethReddy02/mocklet/manager"
+	"io"
 	"os"
 	"path"
 
@@ -39,6 +40,7 @@ import (
 	"k8s.io/client-go/kubernetes/typed/coordination/v1beta1"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 )
@@ -103,8 +105,10 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 	secretInformer := scmInformerFactory.Core().V1().Secrets()
 	configMapInformer := scmInformerFactory.Core().V1().ConfigMaps()
 	serviceInformer := scmInformerFactory.Core().V1().Services()
+	// The claims of the pods are checked before their volumes are mounted.
+	pvcInformer := scmInformerFactory.Core().V1().PersistentVolumeClaims()
 
-	rm, err := manager.NewResourceManager(podInformer.Lister(), secretInformer.Lister(), configMapInformer.Lister(), serviceInformer.Lister())
+	rm, err := manager.NewResourceManager(podInformer.Lister(), secretInformer.Lister(), configMapInformer.Lister(), serviceInformer.Lister(), pvcInformer.Lister())
 	if err != nil {
 		return errors.Wrap(err, "could not create resource manager")
 	}
@@ -118,6 +122,10 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		return err
 	}
 
+	eb := record.NewBroadcaster()
+	eb.StartLogging(log.G(ctx).Infof)
+	eb.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: client.CoreV1().Events(c.KubeNamespace)})
+
 	initConfig := provider.InitConfig{
 		ConfigPath:        c.ProviderConfigPath,
 		NodeName:          c.NodeName,
@@ -126,6 +134,7 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		DaemonPort:        int32(c.ListenPort),
 		InternalIP:        os.Getenv("VKUBELET_POD_IP"),
 		KubeClusterDomain: c.KubeClusterDomain,
+		EventRecorder:     eb.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "kubelet", Host: c.NodeName}),
 	}
 
 	pInit := s.Get(c.Provider)
@@ -137,6 +146,29 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 	if err != nil {
 		return errors.Wrapf(err, "error initializing provider %s", c.Provider)
 	}
+	// Providers that keep state save it on shutdown.
+	if closer, ok := p.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				log.G(ctx).WithError(err).Error("Error closing provider")
+			}
+		}()
+	}
+
+	// The caches the adopted pods read must be synced before they are adopted.
+	synced := []cache.InformerSynced{
+		podInformer.Informer().HasSynced,
+		secretInformer.Informer().HasSynced,
+		configMapInformer.Informer().HasSynced,
+		pvcInformer.Informer().HasSynced,
+	}
+	// Providers that read the namespaces of pods, e.g. to select them by their labels, get
+	// them cached. Others do without, as the informer watches every namespace.
+	if nr, ok := p.(provider.NamespaceReader); ok && nr.ReadsNamespaces() {
+		namespaceInformer := scmInformerFactory.Core().V1().Namespaces()
+		rm.SetNamespaceLister(namespaceInformer.Lister())
+		synced = append(synced, namespaceInformer.Informer().HasSynced)
+	}
 
 	ctx = log.WithLogger(ctx, log.G(ctx).WithFields(log.Fields{
 		"provider":         c.Provider,
@@ -150,9 +182,15 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		leaseClient = client.CoordinationV1beta1().Leases(corev1.NamespaceNodeLease)
 	}
 
+	// Providers that manage the node status push its changes to the node controller.
+	var nodeProvider node.NodeProvider = node.NaiveNodeProvider{}
+	if np, ok := p.(node.NodeProvider); ok {
+		nodeProvider = np
+	}
+
 	pNode := NodeFromProvider(ctx, c.NodeName, taint, p, c.Version)
 	nodeRunner, err := node.NewNodeController(
-		node.NaiveNodeProvider{},
+		nodeProvider,
 		pNode,
 		client.CoreV1().Nodes(),
 		node.WithNodeEnableLeaseV1Beta1(leaseClient, nil),
@@ -176,10 +214,6 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		log.G(ctx).Fatal(err)
 	}
 
-	eb := record.NewBroadcaster()
-	eb.StartLogging(log.G(ctx).Infof)
-	eb.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: client.CoreV1().Events(c.KubeNamespace)})
-
 	pc, err := node.NewPodController(node.PodControllerConfig{
 		PodClient:         client.CoreV1(),
 		PodInformer:       podInformer,
@@ -196,6 +230,38 @@ func RunRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 	go podInformerFactory.Start(ctx.Done())
 	go scmInformerFactory.Start(ctx.Done())
 
+	// Providers that follow the node get it whenever it changes, e.g. once its pod CIDR is
+	// assigned after it registers.
+	if watcher, ok := p.(provider.NodeWatcher); ok {
+		nodeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(
+			client,
+			c.InformerResyncPeriod,
+			kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", c.NodeName).String()
+			}))
+		nodeInformerFactory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				watcher.UpdateNode(ctx, obj.(*corev1.Node))
+			},
+			UpdateFunc: func(_, obj interface{}) {
+				watcher.UpdateNode(ctx, obj.(*corev1.Node))
+			},
+		})
+		go nodeInformerFactory.Start(ctx.Done())
+	}
+
+	// Providers that can adopt the pods already bound to the node do so once the caches
+	// are synced, before the pod controller would create them again. The pods resume their
+	// lifecycle then, which reads their namespace and the objects they reference.
+	if adopter, ok := p.(provider.PodAdopter); ok {
+		if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+			return errors.New("failed to wait for the caches to sync")
+		}
+		if err := adopter.AdoptPods(ctx); err != nil {
+			return errors.Wrap(err, "error adopting pods")
+		}
+	}
+
 	cancelHTTP, err := setupHTTPServer(ctx, p, apiConfig, func(context.Context) ([]*corev1.Pod, error) {
 		return rm.GetPods(), nil
 	})
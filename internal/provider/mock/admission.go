@@ -0,0 +1,245 @@
+package mock
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// Reasons reported by the kubelet for pods rejected on admission, named after the
+// scheduler predicates that failed.
+const (
+	reasonMatchNodeSelector      = "MatchNodeSelector"
+	reasonPodToleratesNodeTaints = "PodToleratesNodeTaints"
+	reasonPodFitsHostPorts       = "PodFitsHostPorts"
+)
+
+// admitPod checks that the pod can run on the node the way the kubelet does before
+// running it, and returns the reason and message of its rejection if it cannot. Only
+// the pods that are still active count against the node allocatable.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) admitPod(key string, pod *v1.Pod) (reason, message string) {
+	var nodeLabels map[string]string
+	var taints []v1.Taint
+	if p.node != nil {
+		nodeLabels, taints = p.node.Labels, p.node.Spec.Taints
+	}
+	if !matchesNodeSelector(pod, p.nodeName, nodeLabels) {
+		return reasonMatchNodeSelector, predicateFailed(reasonMatchNodeSelector)
+	}
+	if !toleratesTaints(pod, taints) {
+		return reasonPodToleratesNodeTaints, predicateFailed(reasonPodToleratesNodeTaints)
+	}
+	if reason, message := p.admitUnderPressure(pod); reason != "" {
+		return reason, message
+	}
+
+	var active []*v1.Pod
+	for k, mp := range p.pods {
+		if k != key && isActive(mp.pod) {
+			active = append(active, mp.pod)
+		}
+	}
+	if conflictingHostPorts(pod, active) {
+		return reasonPodFitsHostPorts, predicateFailed(reasonPodFitsHostPorts)
+	}
+	return p.fitsResources(pod, active)
+}
+
+func predicateFailed(predicate string) string {
+	return fmt.Sprintf("Pod Predicate %s failed", predicate)
+}
+
+// isActive reports whether the pod still holds resources on the node.
+func isActive(pod *v1.Pod) bool {
+	return pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed
+}
+
+// fitsResources checks that the pod fits in what the active pods leave of the node
+// allocatable.
+func (p *MockProvider) fitsResources(pod *v1.Pod, active []*v1.Pod) (reason, message string) {
+	allocatable := p.allocatable
+	if pods := allocatable.Pods().Value(); int64(len(active))+1 > pods {
+		return "OutOfpods", fmt.Sprintf("Pod Node didn't have enough resource: pods, requested: 1, used: %d, capacity: %d", len(active), pods)
+	}
+
+	requested := podRequests(pod)
+	used := v1.ResourceList{}
+	for _, other := range active {
+		addResources(used, podRequests(other))
+	}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		r, u, c := quantityValue(name, requested), quantityValue(name, used), quantityValue(name, allocatable)
+		if r > 0 && r+u > c {
+			return fmt.Sprintf("OutOf%s", name), fmt.Sprintf("Pod Node didn't have enough resource: %s, requested: %d, used: %d, capacity: %d", name, r, u, c)
+		}
+	}
+	return "", ""
+}
+
+// podRequests returns the resources requested by the pod: the sum of the requests of
+// its app containers, or the largest request of an init container if that is more.
+func podRequests(pod *v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, q := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || q.Cmp(current) > 0 {
+				requests[name] = q.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
+
+func addResources(list, add v1.ResourceList) {
+	for name, q := range add {
+		current := list[name]
+		current.Add(q)
+		list[name] = current
+	}
+}
+
+// quantityValue returns the quantity in the unit the kubelet reports it in: millicores
+// for CPU, units otherwise.
+func quantityValue(name v1.ResourceName, list v1.ResourceList) int64 {
+	q, ok := list[name]
+	if !ok {
+		return 0
+	}
+	if name == v1.ResourceCPU {
+		return q.MilliValue()
+	}
+	return q.Value()
+}
+
+// matchesNodeSelector checks the pod's nodeSelector and required node affinity against
+// the node.
+func matchesNodeSelector(pod *v1.Pod, nodeName string, nodeLabels map[string]string) bool {
+	if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(nodeLabels)) {
+		return false
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+	// The terms are ORed, the requirements of a term ANDed.
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if matchesRequirements(term.MatchExpressions, labels.Set(nodeLabels)) &&
+			matchesRequirements(term.MatchFields, labels.Set{"metadata.name": nodeName}) {
+			return true
+		}
+	}
+	return false
+}
+
+var nodeSelectorOperators = map[v1.NodeSelectorOperator]selection.Operator{
+	v1.NodeSelectorOpIn:           selection.In,
+	v1.NodeSelectorOpNotIn:        selection.NotIn,
+	v1.NodeSelectorOpExists:       selection.Exists,
+	v1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	v1.NodeSelectorOpGt:           selection.GreaterThan,
+	v1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+func matchesRequirements(requirements []v1.NodeSelectorRequirement, set labels.Set) bool {
+	selector := labels.NewSelector()
+	for _, r := range requirements {
+		op, ok := nodeSelectorOperators[r.Operator]
+		if !ok {
+			return false
+		}
+		requirement, err := labels.NewRequirement(r.Key, op, r.Values)
+		if err != nil {
+			return false
+		}
+		selector = selector.Add(*requirement)
+	}
+	return selector.Matches(set)
+}
+
+// toleratesTaints checks that the pod tolerates the NoSchedule and NoExecute taints of
+// the node.
+func toleratesTaints(pod *v1.Pod, taints []v1.Taint) bool {
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+type hostPort struct {
+	ip       string
+	protocol v1.Protocol
+	port     int32
+}
+
+// conflictingHostPorts reports whether the pod asks for a host port already used by
+// one of the other pods. A port bound to all addresses conflicts with any address.
+func conflictingHostPorts(pod *v1.Pod, others []*v1.Pod) bool {
+	wanted := hostPorts(pod)
+	if len(wanted) == 0 {
+		return false
+	}
+	for _, other := range others {
+		for _, used := range hostPorts(other) {
+			for _, w := range wanted {
+				if w.port == used.port && w.protocol == used.protocol &&
+					(w.ip == used.ip || w.ip == "0.0.0.0" || used.ip == "0.0.0.0") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func hostPorts(pod *v1.Pod) []hostPort {
+	var ports []hostPort
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.HostPort <= 0 {
+				continue
+			}
+			hp := hostPort{ip: port.HostIP, protocol: port.Protocol, port: port.HostPort}
+			if hp.ip == "" {
+				hp.ip = "0.0.0.0"
+			}
+			if hp.protocol == "" {
+				hp.protocol = v1.ProtocolTCP
+			}
+			ports = append(ports, hp)
+		}
+	}
+	return ports
+}
+
+// rejectPod reports the pod as rejected by the kubelet: failed, without running any of
+// its containers.
+func rejectPod(pod *v1.Pod, reason, message string) {
+	pod.Status = v1.PodStatus{
+		Phase:   v1.PodFailed,
+		Reason:  reason,
+		Message: message,
+	}
+}
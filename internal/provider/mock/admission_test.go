@@ -0,0 +1,77 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAdmission(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{CPU: "2", Memory: "4Gi", Pods: "3"})
+	defer stopPods(p)
+	p.ConfigureNode(context.Background(), &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"type": "mocklet"}},
+		Spec: v1.NodeSpec{Taints: []v1.Taint{
+			{Key: "mocklet.io/provider", Value: "mock", Effect: v1.TaintEffectNoSchedule},
+		}},
+	})
+	tolerate := func(pod *v1.Pod) *v1.Pod {
+		pod.Spec.Tolerations = []v1.Toleration{{Key: "mocklet.io/provider", Operator: v1.TolerationOpExists}}
+		return pod
+	}
+	requests := func(pod *v1.Pod, cpu, memory string) *v1.Pod {
+		pod.Spec.Containers[0].Resources.Requests = v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		}
+		return pod
+	}
+	hostPort := func(pod *v1.Pod, port int32) *v1.Pod {
+		pod.Spec.Containers[0].Ports = []v1.ContainerPort{{ContainerPort: 80, HostPort: port}}
+		return pod
+	}
+
+	admitted := requests(tolerate(newTestPod("admitted", "nginx")), "1500m", "1Gi")
+	admitted.Spec.NodeSelector = map[string]string{"type": "mocklet", "kubernetes.io/os": "linux"}
+	hostPort(admitted, 8080)
+
+	affinity := tolerate(newTestPod("affinity", "nginx"))
+	affinity.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+			{MatchExpressions: []v1.NodeSelectorRequirement{{Key: "type", Operator: v1.NodeSelectorOpIn, Values: []string{"gpu"}}}},
+		}},
+	}}
+	selector := tolerate(newTestPod("selector", "nginx"))
+	selector.Spec.NodeSelector = map[string]string{"type": "gpu"}
+
+	for _, tc := range []struct {
+		pod    *v1.Pod
+		reason string
+	}{
+		{admitted, ""},
+		{newTestPod("untolerated", "nginx"), reasonPodToleratesNodeTaints},
+		{selector, reasonMatchNodeSelector},
+		{affinity, reasonMatchNodeSelector},
+		{hostPort(tolerate(newTestPod("port", "nginx")), 8080), reasonPodFitsHostPorts},
+		{requests(tolerate(newTestPod("cpu", "nginx")), "1", "1Gi"), "OutOfcpu"},
+		{requests(tolerate(newTestPod("memory", "nginx")), "500m", "4Gi"), "OutOfmemory"},
+		{requests(tolerate(newTestPod("small", "nginx")), "500m", "3Gi"), ""},
+		{tolerate(newTestPod("third", "nginx")), ""},
+		{tolerate(newTestPod("fourth", "nginx")), "OutOfpods"},
+	} {
+		if err := p.CreatePod(context.Background(), tc.pod); err != nil {
+			t.Fatal(err)
+		}
+		name := tc.pod.Name
+		pod := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == name })
+		if pod.Status.Reason != tc.reason {
+			t.Fatalf("expected pod %s to be admitted with reason %q, got %q (%s)", tc.pod.Name, tc.reason, pod.Status.Reason, pod.Status.Message)
+		}
+		if tc.reason != "" && pod.Status.Phase != v1.PodFailed {
+			t.Fatalf("expected rejected pod %s to fail, got %s", tc.pod.Name, pod.Status.Phase)
+		}
+	}
+}
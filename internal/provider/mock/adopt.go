@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AdoptPods takes over the pods already bound to the node when mocklet starts, before the
+// pod controller syncs them. Without it the pod controller creates them again, which
+// restarts their containers with new IDs and start times.
+//
+// Adopted pods keep the status they have in the API server, and their lifecycle resumes
+// from there. Statuses that do not add up are repaired, and only those are pushed back.
+// Pods restored from the state file are kept as they are, being more accurate, unless the
+// pod bound to the node is a new one with the same name. Pods that were never started are
+// left to be created.
+//
+// The pods restored from the state file resume their lifecycle first, as it is only called
+// once the caches of the resource manager are synced.
+func (p *MockProvider) AdoptPods(ctx context.Context) error {
+	now := metav1.NewTime(time.Now())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.resumeRestoredPods(now)
+	if p.resourceManager == nil {
+		return nil
+	}
+	pods := p.resourceManager.GetPods()
+
+	var adopted, repaired int
+	for _, pod := range pods {
+		if pod.Status.StartTime == nil {
+			continue
+		}
+		key, err := buildKey(pod)
+		if err != nil {
+			return err
+		}
+		if mp, exists := p.pods[key]; exists {
+			if mp.pod.UID == pod.UID {
+				if !reflect.DeepEqual(mp.pod.Status, pod.Status) {
+					mp.stale = true
+				}
+				continue
+			}
+			mp.stop()
+			p.releasePodIP(mp)
+			delete(p.pods, key)
+		}
+
+		mp := p.adoptPod(key, pod.DeepCopy(), now)
+		adopted++
+		if !reflect.DeepEqual(mp.pod.Status, pod.Status) {
+			mp.stale = true
+			repaired++
+		}
+	}
+	log.G(ctx).Infof("Adopted %d pods bound to the node, repaired the status of %d", adopted, repaired)
+	return nil
+}
+
+// adoptPod takes over a pod that was started before mocklet restarted.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) adoptPod(key string, pod *v1.Pod, now metav1.Time) *mockPod {
+	behavior, err := p.behaviorFor(pod)
+	if err != nil {
+		behavior = p.config.Behavior
+	}
+	var podIP string
+	if isActive(pod) {
+		repairPodStatus(pod, p.internalIP, now)
+		podIP = pod.Status.PodIP
+	}
+	mp := p.restorePod(key, pod, behavior, podIP)
+	p.resumePod(mp, now)
+	p.publishPod(mp)
+	return mp
+}
+
+// repairPodStatus makes the status of an active pod consistent with its spec, so its
+// lifecycle can be resumed: every container has exactly one status, in the order of the
+// spec, app containers have not started while init containers are still running, and
+// running containers have an ID and a start time. The pod runs on this node, whatever host
+// IP it reports. Its phase and conditions are recomputed once its containers are resumed.
+func repairPodStatus(pod *v1.Pod, hostIP string, now metav1.Time) {
+	status := &pod.Status
+	if hostIP != "" {
+		status.HostIP = hostIP
+	}
+	status.InitContainerStatuses = repairContainerStatuses(pod.Spec.InitContainers, status.InitContainerStatuses, reasonPodInitializing, now)
+
+	waitingReason := reasonContainerCreating
+	initialized := true
+	for _, cs := range status.InitContainerStatuses {
+		if t := cs.State.Terminated; t == nil || t.ExitCode != 0 {
+			waitingReason = reasonPodInitializing
+			initialized = false
+		}
+	}
+	status.ContainerStatuses = repairContainerStatuses(pod.Spec.Containers, status.ContainerStatuses, waitingReason, now)
+	if initialized {
+		return
+	}
+	for i := range status.ContainerStatuses {
+		cs := &status.ContainerStatuses[i]
+		if cs.State.Waiting == nil || cs.State.Waiting.Reason != reasonPodInitializing {
+			cs.State = v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: reasonPodInitializing},
+			}
+			cs.Ready = false
+		}
+	}
+}
+
+// repairContainerStatuses returns the statuses of the containers in the order of the spec.
+// Containers without a status, or with a status in no state, are waiting for reason.
+func repairContainerStatuses(containers []v1.Container, statuses []v1.ContainerStatus, reason string, now metav1.Time) []v1.ContainerStatus {
+	if len(containers) == 0 && len(statuses) == 0 {
+		return statuses
+	}
+	repaired := make([]v1.ContainerStatus, len(containers))
+	for i, container := range containers {
+		cs := findContainerStatus(statuses, container.Name)
+		if cs == nil || (cs.State.Waiting == nil && cs.State.Running == nil && cs.State.Terminated == nil) {
+			repaired[i] = newContainerStatus(container, reason)
+			continue
+		}
+		repaired[i] = *cs
+		if running := repaired[i].State.Running; running != nil {
+			if repaired[i].ContainerID == "" {
+				repaired[i].ContainerID = RandStringRunes(64)
+			}
+			if running.StartedAt.IsZero() {
+				running.StartedAt = now
+			}
+		}
+	}
+	return repaired
+}
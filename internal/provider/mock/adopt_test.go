@@ -0,0 +1,95 @@
+package mock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VineethReddy02/mocklet/manager"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestAdoptPods(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	if err := p.CreatePod(context.Background(), newTestPod("web", "app", "sidecar")); err != nil {
+		t.Fatal(err)
+	}
+	web := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "web" && isReady(pod) })
+	stopPods(p)
+
+	// The pods bound to the node, as the API server has them after mocklet restarted.
+	broken := web.DeepCopy()
+	broken.Name, broken.UID = "broken", types.UID("broken")
+	broken.Status.PodIP = ""
+	broken.Status.ContainerStatuses = broken.Status.ContainerStatuses[1:]
+	broken.Status.ContainerStatuses[0].ContainerID = ""
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pod := range []*v1.Pod{web, broken, newTestPod("unstarted", "app")} {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rm, err := manager.NewResourceManager(corev1listers.NewPodLister(indexer), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = NewMockProviderMockConfig(MockConfig{}, "mocklet", "Linux", "10.0.0.1", 10250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopPods(p)
+	p.resourceManager = rm
+	if err := p.AdoptPods(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	notified := make(chan *v1.Pod, 100)
+	p.NotifyPods(context.Background(), func(pod *v1.Pod) {
+		notified <- pod
+	})
+
+	adopted, err := p.GetPod(context.Background(), "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted.Status.PodIP != web.Status.PodIP || adopted.Status.StartTime == nil || !adopted.Status.StartTime.Equal(web.Status.StartTime) {
+		t.Fatalf("expected the pod to keep its IP and start time, got %+v", adopted.Status)
+	}
+	for i, cs := range adopted.Status.ContainerStatuses {
+		if cs.ContainerID != web.Status.ContainerStatuses[i].ContainerID {
+			t.Fatalf("expected container %s to keep its ID", cs.Name)
+		}
+	}
+	if _, err := p.GetPod(context.Background(), "default", "unstarted"); err == nil {
+		t.Fatal("expected the pod that was never started to be left to be created")
+	}
+
+	// Only the repaired pod is pushed.
+	repaired := waitForPod(t, notified, func(pod *v1.Pod) bool {
+		if pod.Name == "web" {
+			t.Fatal("expected the consistent pod not to be pushed")
+		}
+		return pod.Name == "broken" && isReady(pod) && pod.Status.PodIP != ""
+	})
+	if len(repaired.Status.ContainerStatuses) != 2 {
+		t.Fatalf("expected a status for each container, got %+v", repaired.Status.ContainerStatuses)
+	}
+	for _, cs := range repaired.Status.ContainerStatuses {
+		if cs.ContainerID == "" || cs.State.Running == nil {
+			t.Fatalf("expected container %s to be running with an ID, got %+v", cs.Name, cs)
+		}
+	}
+	if repaired.Status.PodIP == web.Status.PodIP {
+		t.Fatal("expected the repaired pod to get an IP of its own")
+	}
+	select {
+	case pod := <-notified:
+		if pod.Name == "web" {
+			t.Fatal("expected the consistent pod not to be pushed")
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
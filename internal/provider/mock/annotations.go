@@ -0,0 +1,107 @@
+package mock
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// reasonInvalidAnnotations is the reason of pods failed because of their mocklet.io
+// annotations.
+const reasonInvalidAnnotations = "InvalidAnnotations"
+
+// Annotations that override the simulated behavior of a single pod. They are read when
+// the pod is created and whenever it is updated.
+const (
+	// annotationStartDelay is the time from the pod being scheduled to its pod sandbox
+	// being created, e.g. "30s".
+	annotationStartDelay = "mocklet.io/start-delay"
+	// annotationRunDuration makes every container of the pod exit after the given
+	// duration, e.g. "5m", whether or not the pod is owned by a Job.
+	annotationRunDuration = "mocklet.io/run-duration"
+	// annotationFailAfter makes every run of the pod's containers crash after the given
+	// duration, e.g. "1m".
+	annotationFailAfter = "mocklet.io/fail-after"
+	// annotationExitCode is the exit code of containers that run to completion or crash.
+	annotationExitCode = "mocklet.io/exit-code"
+	// annotationReady forces the readiness of the pod's containers, "true" or "false",
+	// regardless of their readiness probes.
+	annotationReady = "mocklet.io/ready"
+	// annotationStuckTerminating keeps the pod running once it is deleted, "true" or
+	// "false", so it stays Terminating.
+	annotationStuckTerminating = "mocklet.io/stuck-terminating"
+	// annotationCPUUsage is the CPU usage reported for each container of the pod, e.g. "250m".
+	annotationCPUUsage = "mocklet.io/cpu-usage"
+	// annotationStartupProbePrefix, followed by a container name, holds the JSON encoded
+	// startup probe of that container. The Kubernetes API mocklet is built against
+	// predates spec.containers[].startupProbe, so the field never reaches the provider.
+	annotationStartupProbePrefix = "mocklet.io/startup-probe-"
+)
+
+// applyAnnotations overrides b with the mocklet.io annotations found in annotations.
+func applyAnnotations(b *Behavior, annotations map[string]string) error {
+	if v, ok := annotations[annotationStartDelay]; ok {
+		d, err := parseDuration(annotationStartDelay, v)
+		if err != nil {
+			return err
+		}
+		b.Startup.Started = Distribution{Type: distributionFixed, Value: d}
+	}
+	if v, ok := annotations[annotationRunDuration]; ok {
+		d, err := parseDuration(annotationRunDuration, v)
+		if err != nil {
+			return err
+		}
+		b.Job.Duration = Distribution{Type: distributionFixed, Value: d}
+	}
+	if v, ok := annotations[annotationFailAfter]; ok {
+		d, err := parseDuration(annotationFailAfter, v)
+		if err != nil {
+			return err
+		}
+		b.Crash = CrashConfig{After: Distribution{Type: distributionFixed, Value: d}}
+	}
+	if v, ok := annotations[annotationExitCode]; ok {
+		code, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s: invalid exit code %q", annotationExitCode, v)
+		}
+		b.Job.ExitCode = int32(code)
+		if _, ok := annotations[annotationFailAfter]; ok {
+			// Crashes with exit code 0 would be completions, which run-duration makes.
+			if code == 0 {
+				return fmt.Errorf("%s: crashed containers cannot exit with 0, use %s for containers that complete", annotationExitCode, annotationRunDuration)
+			}
+			b.Crash.ExitCode = int32(code)
+		}
+	}
+	if v, ok := annotations[annotationReady]; ok {
+		ready, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", annotationReady, v)
+		}
+		b.Ready = &ready
+	}
+	if v, ok := annotations[annotationStuckTerminating]; ok {
+		stuck, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: invalid boolean %q", annotationStuckTerminating, v)
+		}
+		b.StuckTerminating = stuck
+	}
+	if v, ok := annotations[annotationCPUUsage]; ok {
+		// The usage set on the pod wins over the models and fixtures of the node and profiles.
+		if err := b.Usage.pinCPU(v); err != nil {
+			return fmt.Errorf("%s: %v", annotationCPUUsage, err)
+		}
+	}
+	return nil
+}
+
+func parseDuration(annotation, v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: invalid duration %q", annotation, v)
+	}
+	return d, nil
+}
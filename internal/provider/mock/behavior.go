@@ -0,0 +1,105 @@
+package mock
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Behavior describes how the pods of a node are simulated: how they start, pull their
+// images, crash, answer their probes, run to completion, use resources, shut down, what
+// they log and how they answer the commands run in them. Node-wide settings can be
+// overridden for a single pod with annotations.
+type Behavior struct {
+	Startup     StartupConfig     `yaml:"startup,omitempty"`
+	ImagePull   ImagePullConfig   `yaml:"imagePull,omitempty"`
+	Crash       CrashConfig       `yaml:"crash,omitempty"`
+	Probes      ProbeConfig       `yaml:"probes,omitempty"`
+	Job         JobConfig         `yaml:"job,omitempty"`
+	Usage       UsageConfig       `yaml:"usage,omitempty"`
+	Termination TerminationConfig `yaml:"termination,omitempty"`
+	Logs        LogsConfig        `yaml:"logs,omitempty"`
+	Exec        ExecConfig        `yaml:"exec,omitempty"`
+	// Ready, when set, forces the readiness of running containers regardless of their
+	// readiness probes.
+	Ready *bool `yaml:"ready,omitempty"`
+	// StuckTerminating keeps deleted pods running, so they stay Terminating.
+	StuckTerminating bool `yaml:"stuckTerminating,omitempty"`
+}
+
+// validate checks the behavior and prepares it for use.
+func (b *Behavior) validate() error {
+	if err := b.Startup.validate(); err != nil {
+		return err
+	}
+	if err := b.ImagePull.compile(); err != nil {
+		return err
+	}
+	if err := b.Crash.validate(); err != nil {
+		return err
+	}
+	if err := b.Probes.validate(); err != nil {
+		return err
+	}
+	if err := b.Usage.compile(); err != nil {
+		return fmt.Errorf("invalid usage: %v", err)
+	}
+	if err := b.Termination.validate(); err != nil {
+		return err
+	}
+	if err := b.Logs.compile(); err != nil {
+		return err
+	}
+	if err := b.Exec.compile(); err != nil {
+		return err
+	}
+	return b.Job.validate()
+}
+
+// behaviorFor resolves the behavior of the given pod: the one of the first profile
+// selecting it or the node's, overridden by the pod's annotations.
+func (p *MockProvider) behaviorFor(pod *v1.Pod) (Behavior, error) {
+	b := p.config.Behavior
+	profile := p.config.profileFor(pod, p.namespaceLabels(pod.Namespace))
+	if profile != nil {
+		b = profile.Behavior
+	}
+	if !isJobPod(pod) && (profile == nil || !profile.completes) {
+		b.Job = JobConfig{}
+	}
+	if err := applyAnnotations(&b, pod.Annotations); err != nil {
+		return b, fmt.Errorf("invalid annotations on pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+	for _, container := range pod.Spec.Containers {
+		if _, err := startupProbe(pod, container.Name); err != nil {
+			return b, fmt.Errorf("invalid annotations on pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+	return b, nil
+}
+
+// ReadsNamespaces tells whether the namespaces of pods are read, which they are if a profile
+// selects pods by the labels of their namespace.
+func (p *MockProvider) ReadsNamespaces() bool {
+	return p.config.selectsNamespaces
+}
+
+// namespaceLabels returns the labels of the namespace, or nil if it is not known.
+func (p *MockProvider) namespaceLabels(name string) labels.Set {
+	if p.resourceManager == nil || !p.config.selectsNamespaces {
+		return nil
+	}
+	namespace, err := p.resourceManager.GetNamespace(name)
+	if err != nil {
+		return nil
+	}
+	return labels.Set(namespace.Labels)
+}
+
+func equalBoolPtr(a, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
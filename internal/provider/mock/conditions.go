@@ -0,0 +1,158 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeConditionConfig declares a condition of the node. Besides the conditions the kubelet
+// reports, it can be any condition set by node-problem-detector and the like, such as
+// KernelDeadlock.
+type NodeConditionConfig struct {
+	Type    string `yaml:"type"`
+	Status  string `yaml:"status"`
+	Reason  string `yaml:"reason,omitempty"`
+	Message string `yaml:"message,omitempty"`
+}
+
+func (c NodeConditionConfig) condition() v1.NodeCondition {
+	return v1.NodeCondition{
+		Type:    v1.NodeConditionType(c.Type),
+		Status:  v1.ConditionStatus(c.Status),
+		Reason:  c.Reason,
+		Message: c.Message,
+	}
+}
+
+// defaultNodeConditions are the conditions of a healthy node. Conditions declared in the
+// configuration replace the default ones of the same type.
+var defaultNodeConditions = []NodeConditionConfig{
+	{"Ready", "True", "KubeletReady", "kubelet is ready."},
+	{"OutOfDisk", "False", "KubeletHasSufficientDisk", "kubelet has sufficient disk space available"},
+	{"MemoryPressure", "False", "KubeletHasSufficientMemory", "kubelet has sufficient memory available"},
+	{"DiskPressure", "False", "KubeletHasNoDiskPressure", "kubelet has no disk pressure"},
+	{"PIDPressure", "False", "KubeletHasSufficientPID", "kubelet has sufficient PID available"},
+	{"NetworkUnavailable", "False", "RouteCreated", "RouteController created a route"},
+}
+
+// pressureConditions are the reasons and messages the kubelet reports with its pressure
+// conditions, when the node is under pressure.
+var pressureConditions = map[v1.NodeConditionType]NodeConditionConfig{
+	v1.NodeMemoryPressure: {"MemoryPressure", "True", "KubeletHasInsufficientMemory", "kubelet has insufficient memory available"},
+	v1.NodeDiskPressure:   {"DiskPressure", "True", "KubeletHasDiskPressure", "kubelet has disk pressure"},
+	v1.NodePIDPressure:    {"PIDPressure", "True", "KubeletHasInsufficientPID", "kubelet has insufficient PID available"},
+}
+
+// validateNodeCondition checks that the condition has a type and a valid status.
+func validateNodeCondition(c v1.NodeCondition) error {
+	if c.Type == "" {
+		return fmt.Errorf("node condition has no type")
+	}
+	switch c.Status {
+	case v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown:
+		return nil
+	default:
+		return fmt.Errorf("invalid status %q of node condition %s, must be True, False or Unknown", c.Status, c.Type)
+	}
+}
+
+func validateNodeConditions(configs []NodeConditionConfig) error {
+	for i, c := range configs {
+		if err := validateNodeCondition(c.condition()); err != nil {
+			return fmt.Errorf("invalid conditions[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// initialNodeConditions returns the default node conditions, replaced or completed by the
+// configured ones.
+func initialNodeConditions(configs []NodeConditionConfig, now metav1.Time) []v1.NodeCondition {
+	var conditions []v1.NodeCondition
+	for _, c := range append(append([]NodeConditionConfig(nil), defaultNodeConditions...), configs...) {
+		conditions, _ = setNodeCondition(conditions, c.condition(), now)
+	}
+	return conditions
+}
+
+// setNodeCondition sets the condition in the list, adding it if the list does not have it
+// yet, and reports whether it changed. Its transition time only changes with its status.
+func setNodeCondition(conditions []v1.NodeCondition, condition v1.NodeCondition, now metav1.Time) ([]v1.NodeCondition, bool) {
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	for i := range conditions {
+		current := &conditions[i]
+		if current.Type != condition.Type {
+			continue
+		}
+		if current.Status == condition.Status {
+			if current.Reason == condition.Reason && current.Message == condition.Message {
+				return conditions, false
+			}
+			condition.LastTransitionTime = current.LastTransitionTime
+		}
+		*current = condition
+		return conditions, true
+	}
+	return append(conditions, condition), true
+}
+
+// GetNodeConditions returns the current conditions of the node.
+func (p *MockProvider) GetNodeConditions(ctx context.Context) []v1.NodeCondition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.nodeConditions()
+}
+
+// SetNodeCondition sets a condition of the node, such as Ready or a custom
+// node-problem-detector condition, adding it if the node does not have it yet.
+func (p *MockProvider) SetNodeCondition(ctx context.Context, condition v1.NodeCondition) error {
+	if err := validateNodeCondition(condition); err != nil {
+		return errdefs.AsInvalidInput(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setNodeCondition(condition, metav1.Now())
+	return nil
+}
+
+// setNodeCondition sets a condition of the node and reports whether it changed.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) setNodeCondition(condition v1.NodeCondition, now metav1.Time) bool {
+	var changed bool
+	p.conditions, changed = setNodeCondition(p.conditions, condition, now)
+	if changed {
+		p.notifyNodeStatus()
+	}
+	return changed
+}
+
+// hasNodeCondition reports whether the condition of the node is True.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) hasNodeCondition(conditionType v1.NodeConditionType) bool {
+	for _, c := range p.conditions {
+		if c.Type == conditionType {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeConditions returns a copy of the conditions of the node, heartbeating now.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) nodeConditions() []v1.NodeCondition {
+	now := metav1.Now()
+	conditions := make([]v1.NodeCondition, len(p.conditions))
+	for i, c := range p.conditions {
+		c.DeepCopyInto(&conditions[i])
+		conditions[i].LastHeartbeatTime = now
+	}
+	return conditions
+}
@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestNodeConditions(t *testing.T) {
+	p, _ := newTestProvider(t, MockConfig{Conditions: []NodeConditionConfig{
+		{Type: "Ready", Status: "False", Reason: "KubeletNotReady", Message: "PLEG is not healthy"},
+		{Type: "KernelDeadlock", Status: "False", Reason: "KernelHasNoDeadlock"},
+	}})
+
+	conditions := p.GetNodeConditions(context.Background())
+	if len(conditions) != len(defaultNodeConditions)+1 {
+		t.Fatalf("expected the default conditions and KernelDeadlock, got %v", conditions)
+	}
+	ready := findNodeCondition(conditions, v1.NodeReady)
+	if ready.Status != v1.ConditionFalse || ready.Reason != "KubeletNotReady" {
+		t.Fatalf("expected the configured Ready condition, got %+v", ready)
+	}
+	if !ready.LastTransitionTime.Equal(&conditions[1].LastTransitionTime) {
+		t.Fatal("expected every condition to transition when the node started")
+	}
+
+	// Changing the message keeps the transition time, changing the status does not.
+	transition := findNodeCondition(conditions, "KernelDeadlock").LastTransitionTime
+	if err := p.SetNodeCondition(context.Background(), v1.NodeCondition{Type: "KernelDeadlock", Status: v1.ConditionFalse, Message: "checked"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := findNodeCondition(p.GetNodeConditions(context.Background()), "KernelDeadlock"); !c.LastTransitionTime.Equal(&transition) || c.Message != "checked" {
+		t.Fatalf("expected the message to change without a transition, got %+v", c)
+	}
+	if err := p.SetNodeCondition(context.Background(), v1.NodeCondition{Type: "KernelDeadlock", Status: v1.ConditionTrue, Reason: "DockerHung"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := findNodeCondition(p.GetNodeConditions(context.Background()), "KernelDeadlock"); c.LastTransitionTime.Equal(&transition) || c.Status != v1.ConditionTrue {
+		t.Fatalf("expected KernelDeadlock to transition to True, got %+v", c)
+	}
+
+	if err := p.SetNodeCondition(context.Background(), v1.NodeCondition{Type: "Ready", Status: "Maybe"}); !errdefs.IsInvalidInput(err) {
+		t.Fatalf("expected an invalid input error, got %v", err)
+	}
+	if _, err := NewMockProviderMockConfig(MockConfig{Conditions: []NodeConditionConfig{{Type: "KernelDeadlock"}}}, "mocklet", "Linux", "10.0.0.1", 10250); err == nil {
+		t.Fatal("expected a condition without status to be rejected")
+	}
+}
+
+func findNodeCondition(conditions []v1.NodeCondition, conditionType v1.NodeConditionType) *v1.NodeCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,635 @@
+package mock
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Defaults of the service account tokens of projected volumes.
+const (
+	defaultTokenExpirationSeconds = 3600
+	defaultTokenIssuer            = "kubernetes/serviceaccount"
+)
+
+// containerFile is a file or a directory of the filesystem of a container.
+type containerFile struct {
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+	// children are the entries of a directory.
+	children map[string]*containerFile
+}
+
+func newContainerDir(modTime time.Time) *containerFile {
+	return &containerFile{mode: os.ModeDir | 0755, modTime: modTime, children: make(map[string]*containerFile)}
+}
+
+func (f *containerFile) isDir() bool {
+	return f.mode.IsDir()
+}
+
+// mkdirAll returns the directory at the slash-separated path below f, creating it and its
+// parents if needed. Files in the way are replaced, like a mount hides what is below it.
+func (f *containerFile) mkdirAll(name string, modTime time.Time) *containerFile {
+	dir := f
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		child := dir.children[part]
+		if child == nil || !child.isDir() {
+			child = newContainerDir(modTime)
+			dir.children[part] = child
+		}
+		dir = child
+	}
+	return dir
+}
+
+// lookup returns the file at the slash-separated path below f, or nil if there is none.
+func (f *containerFile) lookup(name string) *containerFile {
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		if !f.isDir() {
+			return nil
+		}
+		if f = f.children[part]; f == nil {
+			return nil
+		}
+	}
+	return f
+}
+
+// containerFS is the read-only filesystem of a container, as seen by the commands run in
+// it. It holds the files the kubelet writes, such as /etc/hosts, and the contents of the
+// volumes the container mounts. The volumes backed by the API, such as ConfigMaps and
+// Secrets, are resolved when the filesystem is built; the others are empty directories.
+type containerFS struct {
+	root *containerFile
+	// cwd is the working directory of the container, against which relative paths are
+	// resolved.
+	cwd string
+}
+
+// lookup returns the file at the given path, or nil if there is none.
+func (fs *containerFS) lookup(name string) *containerFile {
+	if !path.IsAbs(name) {
+		name = path.Join(fs.cwd, name)
+	}
+	return fs.root.lookup(name)
+}
+
+// containerFS builds the filesystem of container c. Mounts are applied from the shallowest
+// to the deepest, so nested mounts are visible over the volumes they are mounted in.
+func (p *MockProvider) containerFS(pod *v1.Pod, c *v1.Container, allocatable v1.ResourceList) *containerFS {
+	modTime := time.Now()
+	if pod.Status.StartTime != nil {
+		modTime = pod.Status.StartTime.Time
+	}
+	fs := &containerFS{root: newContainerDir(modTime), cwd: "/"}
+	if c.WorkingDir != "" {
+		fs.cwd = path.Clean("/" + c.WorkingDir)
+	}
+	etc := fs.root.mkdirAll("etc", modTime)
+	etc.children["hosts"] = &containerFile{mode: 0644, modTime: modTime, data: []byte(p.hostsFile(pod))}
+	etc.children["hostname"] = &containerFile{mode: 0644, modTime: modTime, data: []byte(p.podHostname(pod) + "\n")}
+
+	volumes := make(map[string]*v1.Volume, len(pod.Spec.Volumes))
+	for i := range pod.Spec.Volumes {
+		volumes[pod.Spec.Volumes[i].Name] = &pod.Spec.Volumes[i]
+	}
+	mounts := append([]v1.VolumeMount(nil), c.VolumeMounts...)
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return strings.Count(path.Clean(mounts[i].MountPath), "/") < strings.Count(path.Clean(mounts[j].MountPath), "/")
+	})
+	for _, m := range mounts {
+		volume := volumes[m.Name]
+		target := path.Clean("/" + m.MountPath)
+		if volume == nil || target == "/" {
+			continue
+		}
+		dir := newContainerDir(modTime)
+		for name, file := range p.volumeFiles(pod, c, volume, allocatable) {
+			parent := dir.mkdirAll(path.Dir(name), modTime)
+			parent.children[path.Base(name)] = &containerFile{mode: os.FileMode(file.mode) & os.ModePerm, modTime: modTime, data: file.data}
+		}
+		mounted := dir
+		if m.SubPath != "" {
+			// The kubelet creates the sub-path when it does not exist in the volume yet.
+			if mounted = dir.lookup(m.SubPath); mounted == nil {
+				mounted = newContainerDir(modTime)
+			}
+		}
+		fs.root.mkdirAll(path.Dir(target), modTime).children[path.Base(target)] = mounted
+	}
+	return fs
+}
+
+// volumeFile is a file of a volume, by its path in the volume.
+type volumeFile struct {
+	data []byte
+	mode int32
+}
+
+// volumeFiles returns the files of the volume. References that cannot be resolved, such
+// as to a ConfigMap that does not exist, contribute no files.
+func (p *MockProvider) volumeFiles(pod *v1.Pod, c *v1.Container, volume *v1.Volume, allocatable v1.ResourceList) map[string]volumeFile {
+	files := make(map[string]volumeFile)
+	switch {
+	case volume.ConfigMap != nil:
+		mode := fileMode(volume.ConfigMap.DefaultMode, v1.ConfigMapVolumeSourceDefaultMode)
+		keyFiles(files, p.configMapData(pod.Namespace, volume.ConfigMap.Name), volume.ConfigMap.Items, mode)
+	case volume.Secret != nil:
+		mode := fileMode(volume.Secret.DefaultMode, v1.SecretVolumeSourceDefaultMode)
+		keyFiles(files, p.secretData(pod.Namespace, volume.Secret.SecretName), volume.Secret.Items, mode)
+	case volume.DownwardAPI != nil:
+		mode := fileMode(volume.DownwardAPI.DefaultMode, v1.DownwardAPIVolumeSourceDefaultMode)
+		downwardAPIFiles(files, pod, c, volume.DownwardAPI.Items, mode, allocatable)
+	case volume.Projected != nil:
+		mode := fileMode(volume.Projected.DefaultMode, v1.ProjectedVolumeSourceDefaultMode)
+		for _, source := range volume.Projected.Sources {
+			switch {
+			case source.ConfigMap != nil:
+				keyFiles(files, p.configMapData(pod.Namespace, source.ConfigMap.Name), source.ConfigMap.Items, mode)
+			case source.Secret != nil:
+				keyFiles(files, p.secretData(pod.Namespace, source.Secret.Name), source.Secret.Items, mode)
+			case source.DownwardAPI != nil:
+				downwardAPIFiles(files, pod, c, source.DownwardAPI.Items, mode, allocatable)
+			case source.ServiceAccountToken != nil:
+				files[source.ServiceAccountToken.Path] = volumeFile{data: serviceAccountToken(pod, source.ServiceAccountToken), mode: mode}
+			}
+		}
+	}
+	return files
+}
+
+func fileMode(mode *int32, def int32) int32 {
+	if mode == nil {
+		return def
+	}
+	return *mode
+}
+
+// configMapData returns the data of the ConfigMap, or nil if it cannot be found.
+func (p *MockProvider) configMapData(namespace, name string) map[string][]byte {
+	if p.resourceManager == nil {
+		return nil
+	}
+	cm, err := p.resourceManager.GetConfigMap(name, namespace)
+	if err != nil {
+		return nil
+	}
+	data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for key, value := range cm.Data {
+		data[key] = []byte(value)
+	}
+	for key, value := range cm.BinaryData {
+		data[key] = value
+	}
+	return data
+}
+
+// secretData returns the data of the Secret, or nil if it cannot be found.
+func (p *MockProvider) secretData(namespace, name string) map[string][]byte {
+	if p.resourceManager == nil {
+		return nil
+	}
+	secret, err := p.resourceManager.GetSecret(name, namespace)
+	if err != nil {
+		return nil
+	}
+	if secret.Data == nil {
+		return map[string][]byte{}
+	}
+	return secret.Data
+}
+
+// keyFiles adds a file per key of data, or per item when items are listed.
+func keyFiles(files map[string]volumeFile, data map[string][]byte, items []v1.KeyToPath, mode int32) {
+	if len(items) == 0 {
+		for key, value := range data {
+			files[key] = volumeFile{data: value, mode: mode}
+		}
+		return
+	}
+	for _, item := range items {
+		if value, ok := data[item.Key]; ok {
+			files[item.Path] = volumeFile{data: value, mode: fileMode(item.Mode, mode)}
+		}
+	}
+}
+
+// downwardAPIFiles adds the files of the downward API items.
+func downwardAPIFiles(files map[string]volumeFile, pod *v1.Pod, c *v1.Container, items []v1.DownwardAPIVolumeFile, mode int32, allocatable v1.ResourceList) {
+	for _, item := range items {
+		var value string
+		switch {
+		case item.FieldRef != nil:
+			value = podFieldValue(pod, item.FieldRef.FieldPath)
+		case item.ResourceFieldRef != nil:
+			container := c
+			if name := item.ResourceFieldRef.ContainerName; name != "" {
+				if container = findPodContainer(pod, name); container == nil {
+					continue
+				}
+			}
+			value = resourceFieldValue(container, item.ResourceFieldRef, allocatable)
+		default:
+			continue
+		}
+		files[item.Path] = volumeFile{data: []byte(value), mode: fileMode(item.Mode, mode)}
+	}
+}
+
+// podFieldValue returns the value of a field of the pod, formatted like the kubelet does.
+func podFieldValue(pod *v1.Pod, fieldPath string) string {
+	if strings.HasPrefix(fieldPath, "metadata.labels['") && strings.HasSuffix(fieldPath, "']") {
+		return pod.Labels[strings.TrimSuffix(strings.TrimPrefix(fieldPath, "metadata.labels['"), "']")]
+	}
+	if strings.HasPrefix(fieldPath, "metadata.annotations['") && strings.HasSuffix(fieldPath, "']") {
+		return pod.Annotations[strings.TrimSuffix(strings.TrimPrefix(fieldPath, "metadata.annotations['"), "']")]
+	}
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name
+	case "metadata.namespace":
+		return pod.Namespace
+	case "metadata.uid":
+		return string(pod.UID)
+	case "metadata.labels":
+		return formatMap(pod.Labels)
+	case "metadata.annotations":
+		return formatMap(pod.Annotations)
+	case "spec.nodeName":
+		return pod.Spec.NodeName
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName
+	case "status.hostIP":
+		return pod.Status.HostIP
+	case "status.podIP":
+		return pod.Status.PodIP
+	}
+	return ""
+}
+
+// formatMap formats labels or annotations as key="value" lines, sorted by key.
+func formatMap(m map[string]string) string {
+	lines := make([]string, 0, len(m))
+	for k, v := range m {
+		lines = append(lines, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// resourceFieldValue returns the request or limit of the container, divided by the divisor
+// of the selector and rounded up. Limits that are not set are the allocatable of the node.
+func resourceFieldValue(c *v1.Container, ref *v1.ResourceFieldSelector, allocatable v1.ResourceList) string {
+	parts := strings.SplitN(ref.Resource, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	name := v1.ResourceName(parts[1])
+	var q resource.Quantity
+	switch parts[0] {
+	case "limits":
+		var ok bool
+		if q, ok = c.Resources.Limits[name]; !ok {
+			q = allocatable[name]
+		}
+	case "requests":
+		q = c.Resources.Requests[name]
+	default:
+		return ""
+	}
+	divisor := ref.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+	if name == v1.ResourceCPU {
+		return strconv.FormatInt(int64(math.Ceil(float64(q.MilliValue())/float64(divisor.MilliValue()))), 10)
+	}
+	return strconv.FormatInt(int64(math.Ceil(float64(q.Value())/float64(divisor.Value()))), 10)
+}
+
+// serviceAccountToken returns a token of the service account of the pod. It is shaped
+// like the tokens of the API server, but its signature is not valid.
+func serviceAccountToken(pod *v1.Pod, projection *v1.ServiceAccountTokenProjection) []byte {
+	expiration := int64(defaultTokenExpirationSeconds)
+	if projection.ExpirationSeconds != nil {
+		expiration = *projection.ExpirationSeconds
+	}
+	account := pod.Spec.ServiceAccountName
+	if account == "" {
+		account = "default"
+	}
+	issued := time.Now().Unix()
+	claims := map[string]interface{}{
+		"iss": defaultTokenIssuer,
+		"sub": fmt.Sprintf("system:serviceaccount:%s:%s", pod.Namespace, account),
+		"iat": issued,
+		"nbf": issued,
+		"exp": issued + expiration,
+		"kubernetes.io": map[string]interface{}{
+			"namespace":      pod.Namespace,
+			"pod":            map[string]string{"name": pod.Name, "uid": string(pod.UID)},
+			"serviceaccount": map[string]string{"name": account},
+		},
+	}
+	if projection.Audience != "" {
+		claims["aud"] = []string{projection.Audience}
+	}
+	payload, _ := json.Marshal(claims)
+	encode := base64.RawURLEncoding.EncodeToString
+	return []byte(encode([]byte(`{"alg":"RS256","kid":"mocklet"}`)) + "." + encode(payload) + "." + encode([]byte("mocklet")))
+}
+
+// execLs lists files like ls, one per line. It supports -a, -A, -l and -1.
+func execLs(s *execSession, fs *containerFS, args []string) int {
+	var all, almostAll, long bool
+	var names []string
+	for _, arg := range args {
+		if len(arg) < 2 || arg[0] != '-' {
+			names = append(names, arg)
+			continue
+		}
+		for _, o := range arg[1:] {
+			switch o {
+			case 'a':
+				all = true
+			case 'A':
+				almostAll = true
+			case 'l':
+				long = true
+			case '1':
+			default:
+				s.stderr(fmt.Sprintf("ls: invalid option -- '%c'\n", o))
+				return 2
+			}
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"."}
+	}
+
+	code := 0
+	var b strings.Builder
+	var dirs []string
+	for _, name := range names {
+		f := fs.lookup(name)
+		switch {
+		case f == nil:
+			s.stderr(fmt.Sprintf("ls: cannot access '%s': No such file or directory\n", name))
+			code = 2
+		case f.isDir():
+			dirs = append(dirs, name)
+		default:
+			writeLsEntry(&b, name, f, long)
+		}
+	}
+	for i, name := range dirs {
+		if i > 0 || b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if len(names) > 1 {
+			b.WriteString(name + ":\n")
+		}
+		dir := fs.lookup(name)
+		entries := make([]string, 0, len(dir.children))
+		for child := range dir.children {
+			if all || almostAll || !strings.HasPrefix(child, ".") {
+				entries = append(entries, child)
+			}
+		}
+		sort.Strings(entries)
+		if long {
+			fmt.Fprintf(&b, "total %d\n", 4*len(entries))
+		}
+		if all {
+			writeLsEntry(&b, ".", dir, long)
+			writeLsEntry(&b, "..", dir, long)
+		}
+		for _, child := range entries {
+			writeLsEntry(&b, child, dir.children[child], long)
+		}
+	}
+	s.stdout(b.String())
+	return code
+}
+
+func writeLsEntry(b *strings.Builder, name string, f *containerFile, long bool) {
+	if !long {
+		b.WriteString(name + "\n")
+		return
+	}
+	links, size := 1, len(f.data)
+	if f.isDir() {
+		links, size = 2, 4096
+		for _, child := range f.children {
+			if child.isDir() {
+				links++
+			}
+		}
+	}
+	fmt.Fprintf(b, "%s %d root root %d %s %s\n", f.mode, links, size, f.modTime.Format("Jan _2 15:04"), name)
+}
+
+// execCat writes the content of files like cat. Without files, it copies its stdin.
+func execCat(ctx context.Context, s *execSession, fs *containerFS, args []string) int {
+	if len(args) == 0 {
+		stdin, stdout := s.attach.Stdin(), s.attach.Stdout()
+		if stdin == nil || stdout == nil {
+			return 0
+		}
+		done := make(chan struct{})
+		go func() {
+			io.Copy(stdout, stdin) //nolint:errcheck
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return 0
+	}
+	code := 0
+	for _, name := range args {
+		f := fs.lookup(name)
+		switch {
+		case f == nil:
+			s.stderr(fmt.Sprintf("cat: %s: No such file or directory\n", name))
+			code = 1
+		case f.isDir():
+			s.stderr(fmt.Sprintf("cat: %s: Is a directory\n", name))
+			code = 1
+		default:
+			s.stdout(string(f.data))
+		}
+	}
+	return code
+}
+
+// execTar creates archives of files on its stdout like tar, as "kubectl cp" does to copy
+// files out of containers. It supports -c, -x, -f -, -C and -z; extracting fails as the
+// filesystem is read-only.
+func execTar(s *execSession, fs *containerFS, args []string) int {
+	var create, extract, gzipped bool
+	archive, dir := "", "."
+	var names []string
+	// The first argument may bundle options without a dash, as in "tar cf - dir".
+	args = append([]string(nil), args...)
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args[0] = "-" + args[0]
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--directory="):
+			dir = strings.TrimPrefix(arg, "--directory=")
+		case strings.HasPrefix(arg, "--file="):
+			archive = strings.TrimPrefix(arg, "--file=")
+		case strings.HasPrefix(arg, "--"):
+			// Other long options, such as --no-same-owner, do not change the archive.
+		case len(arg) < 2 || arg[0] != '-':
+			names = append(names, arg)
+		default:
+			for _, o := range arg[1:] {
+				switch o {
+				case 'c':
+					create = true
+				case 'x':
+					extract = true
+				case 'z':
+					gzipped = true
+				case 'f', 'C':
+					// Options taking a value take the next argument.
+					if i+1 >= len(args) {
+						s.stderr(fmt.Sprintf("tar: option requires an argument -- '%c'\n", o))
+						return 2
+					}
+					i++
+					if o == 'f' {
+						archive = args[i]
+					} else {
+						dir = args[i]
+					}
+				case 'v', 'm', 'o', 'p':
+				default:
+					s.stderr(fmt.Sprintf("tar: invalid option -- '%c'\n", o))
+					return 2
+				}
+			}
+		}
+	}
+	switch {
+	case create == extract:
+		s.stderr("tar: You must specify one of the '-Acdtrux', '--delete' or '--test-label' options\n")
+		return 2
+	case extract:
+		s.stderr(fmt.Sprintf("tar: %s: Cannot open: Read-only file system\n", dir))
+		return 2
+	case archive != "-":
+		s.stderr(fmt.Sprintf("tar: %s: Cannot open: Read-only file system\n", archive))
+		return 2
+	case len(names) == 0:
+		s.stderr("tar: Cowardly refusing to create an empty archive\n")
+		return 2
+	}
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	failed, stripped := false, false
+	for _, name := range names {
+		target := name
+		if !path.IsAbs(target) {
+			target = path.Join(dir, target)
+		}
+		f := fs.lookup(target)
+		if f == nil {
+			s.stderr(fmt.Sprintf("tar: %s: Cannot stat: No such file or directory\n", name))
+			failed = true
+			continue
+		}
+		member := path.Clean(name)
+		if strings.HasPrefix(member, "/") {
+			if !stripped {
+				s.stderr("tar: Removing leading `/' from member names\n")
+				stripped = true
+			}
+			member = strings.TrimLeft(member, "/")
+		}
+		writeTarEntry(tw, member, f) //nolint:errcheck
+	}
+	tw.Close() //nolint:errcheck
+	if gz != nil {
+		gz.Close() //nolint:errcheck
+	}
+	s.stdout(buf.String())
+	if failed {
+		s.stderr("tar: Exiting with failure status due to previous errors\n")
+		return 2
+	}
+	return 0
+}
+
+// writeTarEntry writes the file, or the directory and what it holds, to the archive.
+func writeTarEntry(tw *tar.Writer, name string, f *containerFile) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(f.mode & os.ModePerm),
+		ModTime: f.modTime,
+		Uname:   "root",
+		Gname:   "root",
+	}
+	if !f.isDir() {
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = int64(len(f.data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(f.data)
+		return err
+	}
+	hdr.Typeflag = tar.TypeDir
+	if name != "." && name != "" {
+		hdr.Name = name + "/"
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+	children := make([]string, 0, len(f.children))
+	for child := range f.children {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	for _, child := range children {
+		if err := writeTarEntry(tw, path.Join(name, child), f.children[child]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
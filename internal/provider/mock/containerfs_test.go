@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/VineethReddy02/mocklet/manager"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestContainerFS(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	config := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"},
+		Data:       map[string]string{"app.yaml": "port: 8080\n", "log.yaml": "level: info\n"},
+	}
+	if err := configMaps.Add(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Add(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tls"},
+		Data:       map[string][]byte{"tls.crt": []byte("CERT"), "tls.key": []byte("KEY")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rm, err := manager.NewResourceManager(corev1listers.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})), corev1listers.NewSecretLister(secrets), corev1listers.NewConfigMapLister(configMaps), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.resourceManager = rm
+
+	keyMode := int32(0600)
+	pod := newTestPod("web", "app")
+	pod.Labels = map[string]string{"app": "web", "tier": "frontend"}
+	pod.Spec.Volumes = []v1.Volume{
+		{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "config"}}}},
+		{Name: "tls", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{
+			SecretName: "tls",
+			Items:      []v1.KeyToPath{{Key: "tls.key", Path: "private/tls.key", Mode: &keyMode}},
+		}}},
+		{Name: "podinfo", VolumeSource: v1.VolumeSource{DownwardAPI: &v1.DownwardAPIVolumeSource{Items: []v1.DownwardAPIVolumeFile{
+			{Path: "labels", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels"}},
+			{Path: "cpu_limit", ResourceFieldRef: &v1.ResourceFieldSelector{Resource: "limits.cpu", Divisor: resource.MustParse("1m")}},
+		}}}},
+		{Name: "cache", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+	}
+	pod.Spec.Containers[0].Resources.Limits = v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")}
+	pod.Spec.Containers[0].WorkingDir = "/etc/config"
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+		{Name: "config", MountPath: "/etc/config"},
+		{Name: "config", MountPath: "/etc/log.yaml", SubPath: "log.yaml"},
+		{Name: "tls", MountPath: "/etc/config/tls"},
+		{Name: "podinfo", MountPath: "/etc/podinfo"},
+		{Name: "cache", MountPath: "/cache"},
+	}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "web" && pod.Status.Phase == v1.PodRunning })
+
+	run := func(cmd ...string) string {
+		t.Helper()
+		a := &fakeAttach{}
+		if err := p.RunInContainer(context.Background(), "default", "web", "app", cmd, a); err != nil {
+			t.Fatalf("%s: %v: %s", strings.Join(cmd, " "), err, a.stderr.String())
+		}
+		return a.stdout.String()
+	}
+
+	// Relative paths are resolved against the working directory, and nested mounts
+	// show over the volume they are mounted in.
+	if out := run("ls"); out != "app.yaml\nlog.yaml\ntls\n" {
+		t.Fatalf("unexpected listing %q", out)
+	}
+	if out := run("ls", "-l", "tls/private"); !strings.HasPrefix(out, "total 4\n-rw------- 1 root root 3 ") {
+		t.Fatalf("expected the mode of the item, got %q", out)
+	}
+	if out := run("cat", "/etc/log.yaml", "app.yaml"); out != "level: info\nport: 8080\n" {
+		t.Fatalf("unexpected content %q", out)
+	}
+	if out := run("cat", "/etc/podinfo/labels", "/etc/podinfo/cpu_limit"); out != "app=\"web\"\ntier=\"frontend\"500" {
+		t.Fatalf("unexpected downward API files %q", out)
+	}
+	if out := run("ls", "/cache"); out != "" {
+		t.Fatalf("expected an empty directory, got %q", out)
+	}
+	a := &fakeAttach{}
+	if err := p.RunInContainer(context.Background(), "default", "web", "app", []string{"cat", "missing.yaml"}, a); err == nil || a.stderr.String() != "cat: missing.yaml: No such file or directory\n" {
+		t.Fatalf("expected missing files to fail, got %q and %v", a.stderr.String(), err)
+	}
+
+	// Files are resolved again at every command, so updates show up.
+	updated := config.DeepCopy()
+	updated.Data["app.yaml"] = "port: 9090\n"
+	if err := configMaps.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	// kubectl cp reads the files through tar.
+	tr := tar.NewReader(strings.NewReader(run("tar", "cf", "-", "-C", "/etc", "config")))
+	var members []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, hdr.Name)
+		if hdr.Name == "config/app.yaml" {
+			data, _ := ioutil.ReadAll(tr)
+			if !bytes.Equal(data, []byte("port: 9090\n")) {
+				t.Fatalf("expected the updated ConfigMap, got %q", data)
+			}
+		}
+	}
+	want := "config/ config/app.yaml config/log.yaml config/tls/ config/tls/private/ config/tls/private/tls.key"
+	if got := strings.Join(members, " "); got != want {
+		t.Fatalf("expected the archive to hold %q, got %q", want, got)
+	}
+}
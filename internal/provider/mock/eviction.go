@@ -0,0 +1,371 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultEvictionInterval is how often eviction thresholds are checked, like the
+// kubelet's housekeeping interval.
+const defaultEvictionInterval = 10 * time.Second
+
+// reasonEvicted is the reason of pods evicted, or rejected on admission, because the node
+// is under pressure.
+const reasonEvicted = "Evicted"
+
+// Eviction signals, named after the kubelet's.
+const (
+	signalMemoryAvailable signal = "memory.available"
+	signalNodeFsAvailable signal = "nodefs.available"
+	signalPIDAvailable    signal = "pid.available"
+)
+
+type signal string
+
+// signalConditions are the node conditions reported while a signal crosses its threshold.
+var signalConditions = map[signal]v1.NodeConditionType{
+	signalMemoryAvailable: v1.NodeMemoryPressure,
+	signalNodeFsAvailable: v1.NodeDiskPressure,
+	signalPIDAvailable:    v1.NodePIDPressure,
+}
+
+// signalResources are the resources reported as starved when pods are evicted for a signal.
+var signalResources = map[signal]v1.ResourceName{
+	signalMemoryAvailable: v1.ResourceMemory,
+	signalNodeFsAvailable: v1.ResourceEphemeralStorage,
+	signalPIDAvailable:    "pids",
+}
+
+// EvictionConfig sets the thresholds under which pods are evicted from the node. Thresholds
+// are checked against the usage reported in the stats summary.
+type EvictionConfig struct {
+	// Hard maps eviction signals (memory.available, nodefs.available and pid.available)
+	// to the threshold under which pods are evicted right away, as a quantity such as
+	// "100Mi" or a percentage of the capacity such as "10%".
+	Hard map[string]string `yaml:"hard,omitempty"`
+	// Interval is how often the thresholds are checked. Defaults to 10s.
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	thresholds map[signal]threshold
+}
+
+// threshold is either an absolute quantity or a fraction of the capacity.
+type threshold struct {
+	quantity   int64
+	percentage float64
+}
+
+// value returns the threshold for the given capacity.
+func (t threshold) value(capacity int64) int64 {
+	if t.percentage > 0 {
+		return int64(float64(capacity) * t.percentage)
+	}
+	return t.quantity
+}
+
+// compile validates the configuration and parses the thresholds.
+func (c *EvictionConfig) compile() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid eviction.interval: negative duration")
+	}
+	c.thresholds = make(map[signal]threshold)
+	for name, v := range c.Hard {
+		s := signal(name)
+		if _, ok := signalConditions[s]; !ok {
+			return fmt.Errorf("unsupported eviction signal %q", name)
+		}
+		t, err := parseThreshold(v)
+		if err != nil {
+			return fmt.Errorf("invalid eviction threshold %s=%q: %v", name, v, err)
+		}
+		c.thresholds[s] = t
+	}
+	return nil
+}
+
+func parseThreshold(v string) (threshold, error) {
+	if strings.HasSuffix(v, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil || percentage <= 0 || percentage > 100 {
+			return threshold{}, fmt.Errorf("percentage must be in (0, 100]")
+		}
+		return threshold{percentage: percentage / 100}, nil
+	}
+	q, err := resource.ParseQuantity(v)
+	if err != nil {
+		return threshold{}, err
+	}
+	if q.Sign() < 0 {
+		return threshold{}, fmt.Errorf("negative quantity")
+	}
+	return threshold{quantity: q.Value()}, nil
+}
+
+// observation is the available amount and the capacity of the resource behind a signal.
+type observation struct {
+	available int64
+	capacity  int64
+}
+
+// observeSignals computes the eviction signals from the usage of the active pods.
+func (p *MockProvider) observeSignals(usage map[*mockPod][]containerUsage) map[signal]observation {
+	var total containerUsage
+	for _, u := range usage {
+		total.add(podUsage(u))
+	}
+	capacity := p.capacity
+	memory := capacity.Memory().Value()
+	storage := capacity.StorageEphemeral().Value()
+	return map[signal]observation{
+		signalMemoryAvailable: {available: memory - int64(total.memoryBytes), capacity: memory},
+		signalNodeFsAvailable: {available: storage - int64(total.diskBytes), capacity: storage},
+		signalPIDAvailable:    {available: p.config.MaxPIDs - int64(total.processes), capacity: p.config.MaxPIDs},
+	}
+}
+
+// runEvictionManager checks the eviction thresholds every interval until ctx is done.
+func (p *MockProvider) runEvictionManager(ctx context.Context) {
+	interval := p.config.Eviction.Interval
+	if interval == 0 {
+		interval = defaultEvictionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if pod := p.synchronizeEviction(metav1.Now()); pod != nil {
+				log.G(ctx).Infof("evicted pod %s/%s: %s", pod.Namespace, pod.Name, pod.Status.Message)
+			}
+		}
+	}
+}
+
+// synchronizeEviction updates the pressure conditions of the signals with a threshold from
+// the current usage and, like the kubelet, evicts at most one pod if a threshold is
+// crossed. It returns the evicted pod.
+func (p *MockProvider) synchronizeEviction(now metav1.Time) *v1.Pod {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Pods already shutting down still use resources, but cannot be evicted.
+	usage := make(map[*mockPod][]containerUsage)
+	candidates := make(map[*mockPod][]containerUsage)
+	for _, mp := range p.pods {
+		if !isActive(mp.pod) {
+			continue
+		}
+		usage[mp] = mp.usage(now.Time, p.allocatable)
+		if !mp.terminating {
+			candidates[mp] = usage[mp]
+		}
+	}
+	observations := p.observeSignals(usage)
+
+	var starved []signal
+	for s, t := range p.config.Eviction.thresholds {
+		o := observations[s]
+		condition := signalConditions[s]
+		if o.available < t.value(o.capacity) {
+			starved = append(starved, s)
+			p.setNodeCondition(pressureConditions[condition].condition(), now)
+			continue
+		}
+		for _, c := range defaultNodeConditions {
+			if c.Type == string(condition) {
+				p.setNodeCondition(c.condition(), now)
+			}
+		}
+	}
+	if len(starved) == 0 || len(candidates) == 0 {
+		return nil
+	}
+	// Reclaim memory first, as the kubelet does, then the other resources in a stable order.
+	sort.Slice(starved, func(i, j int) bool {
+		if (starved[i] == signalMemoryAvailable) != (starved[j] == signalMemoryAvailable) {
+			return starved[i] == signalMemoryAvailable
+		}
+		return starved[i] < starved[j]
+	})
+	mp := rankForEviction(starved[0], candidates)
+	p.evictPod(mp, starved[0], usage[mp], now)
+	p.notifyPod(mp)
+	return mp.pod.DeepCopy()
+}
+
+// rankForEviction returns the pod to evict first for the signal: best-effort pods before
+// burstable ones before guaranteed ones, then pods of lower priority, then pods using the
+// most of the starved resource above their requests.
+func rankForEviction(s signal, usage map[*mockPod][]containerUsage) *mockPod {
+	var candidates []*mockPod
+	for mp := range usage {
+		candidates = append(candidates, mp)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if qa, qb := qosRank(a.pod), qosRank(b.pod); qa != qb {
+			return qa < qb
+		}
+		if pa, pb := podPriority(a.pod), podPriority(b.pod); pa != pb {
+			return pa < pb
+		}
+		if ea, eb := exceededRequests(s, a.pod, usage[a]), exceededRequests(s, b.pod, usage[b]); ea != eb {
+			return ea > eb
+		}
+		// Keep the order stable between runs.
+		return a.pod.Namespace+"/"+a.pod.Name < b.pod.Namespace+"/"+b.pod.Name
+	})
+	return candidates[0]
+}
+
+func podPriority(pod *v1.Pod) int32 {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority
+	}
+	return 0
+}
+
+// exceededRequests returns how much of the signal's resource the pod uses above its
+// requests.
+func exceededRequests(s signal, pod *v1.Pod, usage []containerUsage) int64 {
+	total := podUsage(usage)
+	var used int64
+	switch s {
+	case signalMemoryAvailable:
+		used = int64(total.memoryBytes)
+	case signalNodeFsAvailable:
+		used = int64(total.diskBytes)
+	default:
+		return int64(total.processes)
+	}
+	return used - quantityValue(signalResources[s], podRequests(pod))
+}
+
+// qosRank orders the QoS classes of pods from the first evicted to the last.
+func qosRank(pod *v1.Pod) int {
+	switch getPodQOS(pod) {
+	case v1.PodQOSBestEffort:
+		return 0
+	case v1.PodQOSBurstable:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// getPodQOS returns the QoS class of the pod: guaranteed if all its containers have
+// equal CPU and memory requests and limits, best-effort if none has any, burstable otherwise.
+func getPodQOS(pod *v1.Pod) v1.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+	tracked := []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+	bestEffort, guaranteed := true, true
+	containers := append(append([]v1.Container(nil), pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		for _, name := range tracked {
+			request, hasRequest := container.Resources.Requests[name]
+			limit, hasLimit := container.Resources.Limits[name]
+			if (hasRequest && !request.IsZero()) || (hasLimit && !limit.IsZero()) {
+				bestEffort = false
+			}
+			if !hasLimit || limit.IsZero() || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case bestEffort:
+		return v1.PodQOSBestEffort
+	case guaranteed:
+		return v1.PodQOSGuaranteed
+	default:
+		return v1.PodQOSBurstable
+	}
+}
+
+// evictPod kills the containers of the pod and reports it as evicted. Like rejected pods,
+// evicted pods stay on the node until they are deleted.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) evictPod(mp *mockPod, s signal, usage []containerUsage, now metav1.Time) {
+	mp.cancel()
+	p.releasePodIP(mp)
+	mp.forEachContainer(func(ref containerRef) {
+		cs := ref.status(mp.pod)
+		switch {
+		case cs.State.Running != nil:
+			terminateContainer(cs, exitCodeKilled, reasonError, "", now)
+		case cs.State.Terminated == nil:
+			terminateContainer(cs, 0, "MockProviderPodEvicted", "", now)
+		}
+	})
+	status := &mp.pod.Status
+	status.Phase = v1.PodFailed
+	status.Reason = reasonEvicted
+	status.Message = evictionMessage(s, mp.pod, usage)
+	updateReadiness(status, now)
+}
+
+// evictionMessage describes the eviction the way the kubelet does, naming the containers
+// using more than they requested of the starved resource.
+func evictionMessage(s signal, pod *v1.Pod, usage []containerUsage) string {
+	resourceName := signalResources[s]
+	message := fmt.Sprintf("The node was low on resource: %s. ", resourceName)
+	if s == signalPIDAvailable {
+		return message
+	}
+	for i, container := range pod.Spec.Containers {
+		used := usage[i].memoryBytes
+		if s == signalNodeFsAvailable {
+			used = usage[i].diskBytes
+		}
+		request := container.Resources.Requests[resourceName]
+		if int64(used) > request.Value() {
+			message += fmt.Sprintf("Container %s was using %s, which exceeds its request of %s. ",
+				container.Name, resource.NewQuantity(int64(used), resource.BinarySI), request.String())
+		}
+	}
+	return message
+}
+
+// underPressure returns the pressure conditions of the node that are True, in the order
+// the kubelet reports them.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) underPressure() []v1.NodeConditionType {
+	var conditions []v1.NodeConditionType
+	for _, c := range []v1.NodeConditionType{v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure} {
+		if p.hasNodeCondition(c) {
+			conditions = append(conditions, c)
+		}
+	}
+	return conditions
+}
+
+// admitUnderPressure rejects pods the way the kubelet does while the node is under
+// pressure: under memory pressure only best-effort pods are rejected, under any other
+// pressure every pod is.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) admitUnderPressure(pod *v1.Pod) (reason, message string) {
+	conditions := p.underPressure()
+	if len(conditions) == 0 {
+		return "", ""
+	}
+	if len(conditions) == 1 && conditions[0] == v1.NodeMemoryPressure && getPodQOS(pod) != v1.PodQOSBestEffort {
+		return "", ""
+	}
+	return reasonEvicted, fmt.Sprintf("The node had condition: %v. ", conditions)
+}
@@ -0,0 +1,110 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEviction(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{
+		Memory:   "4Gi",
+		Behavior: Behavior{Usage: UsageConfig{Memory: "1Gi"}},
+		Eviction: EvictionConfig{Hard: map[string]string{"memory.available": "1536Mi"}},
+	})
+	defer stopPods(p)
+	resources := func(pod *v1.Pod, requests, limits string) *v1.Pod {
+		r := &pod.Spec.Containers[0].Resources
+		r.Requests = v1.ResourceList{v1.ResourceMemory: resource.MustParse(requests)}
+		if limits != "" {
+			r.Requests[v1.ResourceCPU] = resource.MustParse("100m")
+			r.Limits = v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse(limits)}
+		}
+		return pod
+	}
+	priority := int32(10)
+	high := resources(newTestPod("burstable-high", "app"), "256Mi", "")
+	high.Spec.Priority = &priority
+
+	for _, pod := range []*v1.Pod{
+		resources(newTestPod("guaranteed", "app"), "1Gi", "1Gi"),
+		high,
+		resources(newTestPod("burstable-large", "app"), "1Gi", ""),
+		resources(newTestPod("burstable-small", "app"), "512Mi", ""),
+		newTestPod("best-effort", "app"),
+	} {
+		if err := p.CreatePod(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+		name := pod.Name
+		waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == name && pod.Status.Phase == v1.PodRunning })
+	}
+
+	for _, name := range []string{"best-effort", "burstable-small", "burstable-large", ""} {
+		evicted := p.synchronizeEviction(metav1.Now())
+		if name == "" {
+			if evicted != nil {
+				t.Fatalf("expected no more evictions, got %s", evicted.Name)
+			}
+			break
+		}
+		if evicted == nil || evicted.Name != name {
+			t.Fatalf("expected %s to be evicted, got %v", name, evicted)
+		}
+		if evicted.Status.Phase != v1.PodFailed || evicted.Status.Reason != reasonEvicted {
+			t.Fatalf("expected evicted pod to fail with reason Evicted, got %s %q", evicted.Status.Phase, evicted.Status.Reason)
+		}
+		if state := evicted.Status.ContainerStatuses[0].State; state.Terminated == nil || state.Terminated.ExitCode != exitCodeKilled {
+			t.Fatalf("expected the container of the evicted pod to be killed, got %+v", state)
+		}
+		if !hasCondition(p.GetNodeConditions(context.Background()), v1.NodeMemoryPressure) {
+			t.Fatal("expected the node to report memory pressure")
+		}
+		if name == "best-effort" {
+			// Best-effort pods are rejected while the node is under memory pressure.
+			if err := p.CreatePod(context.Background(), newTestPod("rejected", "app")); err != nil {
+				t.Fatal(err)
+			}
+			pod := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "rejected" })
+			if pod.Status.Reason != reasonEvicted {
+				t.Fatalf("expected best-effort pod to be rejected under memory pressure, got %q", pod.Status.Reason)
+			}
+		}
+	}
+	if hasCondition(p.GetNodeConditions(context.Background()), v1.NodeMemoryPressure) {
+		t.Fatal("expected memory pressure to be relieved")
+	}
+
+	summary, err := p.GetStatsSummary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available := *summary.Node.Memory.AvailableBytes; available != 2<<30 {
+		t.Fatalf("expected 2Gi of memory available, got %d", available)
+	}
+}
+
+func hasCondition(conditions []v1.NodeCondition, conditionType v1.NodeConditionType) bool {
+	for _, c := range conditions {
+		if c.Type == conditionType {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func TestEvictionConfigValidation(t *testing.T) {
+	for _, hard := range []map[string]string{
+		{"imagefs.available": "10%"},
+		{"memory.available": "150%"},
+		{"nodefs.available": "lots"},
+	} {
+		config := EvictionConfig{Hard: hard}
+		if err := config.compile(); err == nil {
+			t.Fatalf("expected thresholds %v to be rejected", hard)
+		}
+	}
+}
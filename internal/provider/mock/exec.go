@@ -0,0 +1,437 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	v1 "k8s.io/api/core/v1"
+)
+
+// exitCodeCannotExecute is the exit code container runtimes report for commands whose
+// executable cannot be run, such as one that is not in the image.
+const exitCodeCannotExecute = 126
+
+// execPath is the PATH of the containers, the default of most images.
+const execPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// termSizeWait is how long commands reading the size of the terminal wait for the client
+// to send it, as it does right after the command starts. A variable so tests can shorten it.
+var termSizeWait = 500 * time.Millisecond
+
+// ExecConfig scripts the responses to the commands run in containers, such as with
+// "kubectl exec".
+type ExecConfig struct {
+	// Rules script the response to matching commands. The first matching rule applies.
+	// Commands no rule matches are answered by the built-in commands: env, hostname, sleep,
+	// stty size, tput cols|lines, and ls, cat and tar, which read the files of the
+	// container's filesystem. Any other command fails as if its executable was not in the
+	// image.
+	Rules []ExecRule `yaml:"rules,omitempty"`
+}
+
+// ExecRule scripts the response to matching commands.
+type ExecRule struct {
+	// Command is a glob matched against the command and its arguments joined by spaces,
+	// such as "/healthz.sh *". "*" matches any sequence of characters, "?" a single one.
+	Command string `yaml:"command,omitempty"`
+	// Regex is a regular expression matched against the command and its arguments joined
+	// by spaces.
+	Regex string `yaml:"regex,omitempty"`
+	// Image is a glob restricting the rule to the containers running a matching image.
+	Image string `yaml:"image,omitempty"`
+	// Stdout and Stderr are written to the standard output and error of the command.
+	Stdout string `yaml:"stdout,omitempty"`
+	Stderr string `yaml:"stderr,omitempty"`
+	// ExitCode is the exit code of the command.
+	ExitCode int `yaml:"exitCode,omitempty"`
+	// Latency is how long the command runs before it responds.
+	Latency Distribution `yaml:"latency,omitempty"`
+
+	re    *regexp.Regexp
+	image *regexp.Regexp
+}
+
+// compile validates the configuration and prepares the rules for matching.
+func (c *ExecConfig) compile() error {
+	c.Rules = append([]ExecRule(nil), c.Rules...)
+	for i := range c.Rules {
+		if err := c.Rules[i].compile(); err != nil {
+			return fmt.Errorf("invalid exec.rules[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *ExecRule) compile() error {
+	if r.ExitCode < 0 || r.ExitCode > 255 {
+		return fmt.Errorf("exit code %d is not between 0 and 255", r.ExitCode)
+	}
+	if err := r.Latency.validate(); err != nil {
+		return fmt.Errorf("invalid latency: %v", err)
+	}
+	var exprs []string
+	if r.Command != "" {
+		exprs = append(exprs, globExpr(r.Command))
+	}
+	if r.Regex != "" {
+		exprs = append(exprs, "(?:"+r.Regex+")")
+	}
+	if len(exprs) == 0 {
+		return fmt.Errorf("one of command or regex is required")
+	}
+	re, err := regexp.Compile(strings.Join(exprs, "|"))
+	if err != nil {
+		return err
+	}
+	r.re = re
+	r.image = nil
+	if r.Image != "" {
+		r.image = regexp.MustCompile(globExpr(r.Image))
+	}
+	return nil
+}
+
+// ruleFor returns the rule scripting the response to cmd run in a container running image,
+// or nil if none does.
+func (c ExecConfig) ruleFor(image string, cmd []string) *ExecRule {
+	command := strings.Join(cmd, " ")
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.re.MatchString(command) && (r.image == nil || r.image.MatchString(image)) {
+			return r
+		}
+	}
+	return nil
+}
+
+// RunInContainer runs a command in a container of the pod. Its response is scripted by
+// the exec rules of the pod's behavior, or given by the built-in commands, and written to
+// the streams of the exec session. A non-zero exit code is returned as an exitError, which
+// the kubelet API reports to the client.
+func (p *MockProvider) RunInContainer(ctx context.Context, namespace, name, container string, cmd []string, attach api.AttachIO) error {
+	log.G(ctx).Infof("receive ExecInContainer %q", container)
+	key, err := buildKeyFromNames(namespace, name)
+	if err != nil {
+		return err
+	}
+	if len(cmd) == 0 {
+		return errdefs.InvalidInput("no command given")
+	}
+
+	p.mu.Lock()
+	mp, exists := p.pods[key]
+	if !exists {
+		p.mu.Unlock()
+		return errdefs.NotFoundf("pod \"%s/%s\" is not known to the provider", namespace, name)
+	}
+	cs := findPodContainerStatus(mp.pod, container)
+	c := findPodContainer(mp.pod, container)
+	if cs == nil || c == nil {
+		p.mu.Unlock()
+		return errdefs.NotFoundf("container %q not found in pod \"%s/%s\"", container, namespace, name)
+	}
+	if cs.State.Running == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("container %q in pod \"%s/%s\" is not running", container, namespace, name)
+	}
+	config := mp.behavior.Exec
+	pod := mp.pod.DeepCopy()
+	c = findPodContainer(pod, container)
+	allocatable := p.allocatable.DeepCopy()
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s := newExecSession(ctx, attach)
+	fs := p.containerFS(pod, c, allocatable)
+	code := p.exec(ctx, s, fs, pod, c, config, cmd)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if code != 0 {
+		return exitError(code)
+	}
+	return nil
+}
+
+// exec runs the command and returns its exit code.
+func (p *MockProvider) exec(ctx context.Context, s *execSession, fs *containerFS, pod *v1.Pod, c *v1.Container, config ExecConfig, cmd []string) int {
+	if r := config.ruleFor(c.Image, cmd); r != nil {
+		if !sleepContext(ctx, r.Latency.Sample()) {
+			return 0
+		}
+		s.stdout(r.Stdout)
+		s.stderr(r.Stderr)
+		return r.ExitCode
+	}
+
+	args := cmd[1:]
+	switch path.Base(cmd[0]) {
+	case "env", "printenv":
+		if len(args) == 0 {
+			s.stdout(strings.Join(p.containerEnv(pod, c), "\n") + "\n")
+			return 0
+		}
+	case "hostname":
+		if len(args) == 0 {
+			s.stdout(p.podHostname(pod) + "\n")
+			return 0
+		}
+	case "ls":
+		return execLs(s, fs, args)
+	case "cat":
+		return execCat(ctx, s, fs, args)
+	case "tar":
+		return execTar(s, fs, args)
+	case "sleep":
+		return execSleep(ctx, s, args)
+	case "stty":
+		if len(args) == 1 && args[0] == "size" {
+			size, ok := s.termSize(ctx)
+			if !ok {
+				s.stderr("stty: 'standard input': Inappropriate ioctl for device\n")
+				return 1
+			}
+			s.stdout(fmt.Sprintf("%d %d\n", size.Height, size.Width))
+			return 0
+		}
+	case "tput":
+		if len(args) == 1 && (args[0] == "cols" || args[0] == "lines") {
+			// Without a terminal tput falls back to the size of a VT100.
+			size, ok := s.termSize(ctx)
+			if !ok {
+				size = api.TermSize{Width: 80, Height: 24}
+			}
+			if args[0] == "cols" {
+				s.stdout(fmt.Sprintf("%d\n", size.Width))
+			} else {
+				s.stdout(fmt.Sprintf("%d\n", size.Height))
+			}
+			return 0
+		}
+	}
+	s.stderr(fmt.Sprintf("OCI runtime exec failed: exec failed: container_linux.go:349: starting container process caused \"exec: \\\"%s\\\": executable file not found in $PATH\": unknown\n", cmd[0]))
+	return exitCodeCannotExecute
+}
+
+// exitError is the error of a command that exited with a non-zero code. It implements the
+// ExitError interface of k8s.io/utils/exec, whose exit code the kubelet API sends to the
+// client.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("command terminated with exit code %d", int(e))
+}
+
+func (e exitError) String() string {
+	return e.Error()
+}
+
+func (e exitError) Exited() bool {
+	return true
+}
+
+func (e exitError) ExitStatus() int {
+	return int(e)
+}
+
+// execSleep sleeps for the sum of its arguments, which are numbers of seconds optionally
+// suffixed by s, m, h or d, like GNU sleep.
+func execSleep(ctx context.Context, s *execSession, args []string) int {
+	if len(args) == 0 {
+		s.stderr("sleep: missing operand\n")
+		return 1
+	}
+	var total time.Duration
+	for _, arg := range args {
+		unit := time.Second
+		value := arg
+		if n := len(arg); n > 0 {
+			switch arg[n-1] {
+			case 's':
+				value = arg[:n-1]
+			case 'm':
+				unit, value = time.Minute, arg[:n-1]
+			case 'h':
+				unit, value = time.Hour, arg[:n-1]
+			case 'd':
+				unit, value = 24*time.Hour, arg[:n-1]
+			}
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			s.stderr(fmt.Sprintf("sleep: invalid time interval '%s'\n", arg))
+			return 1
+		}
+		total += time.Duration(f * float64(unit))
+	}
+	sleepContext(ctx, total)
+	return 0
+}
+
+// sleepContext sleeps for d, and reports whether it did before the context was done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// podHostname returns the hostname of the pod, as the kubelet sets it.
+func (p *MockProvider) podHostname(pod *v1.Pod) string {
+	if pod.Spec.HostNetwork {
+		return p.nodeName
+	}
+	hostname := pod.Name
+	if pod.Spec.Hostname != "" {
+		hostname = pod.Spec.Hostname
+	}
+	if len(hostname) > 63 {
+		hostname = strings.TrimRight(hostname[:63], "-.")
+	}
+	return hostname
+}
+
+// containerEnv returns the environment of the container: the PATH of the image, the
+// hostname, the variables of the container, which have been resolved from its env,
+// envFrom and the services of the namespace when it was created, and HOME.
+func (p *MockProvider) containerEnv(pod *v1.Pod, c *v1.Container) []string {
+	env := []string{"PATH=" + execPath, "HOSTNAME=" + p.podHostname(pod)}
+	vars := make([]string, 0, len(c.Env))
+	for _, e := range c.Env {
+		vars = append(vars, e.Name+"="+e.Value)
+	}
+	sort.Strings(vars)
+	return append(append(env, vars...), "HOME=/root")
+}
+
+// hostsFile returns the /etc/hosts file the kubelet writes for the pod.
+func (p *MockProvider) hostsFile(pod *v1.Pod) string {
+	var b strings.Builder
+	b.WriteString("# Kubernetes-managed hosts file.\n")
+	b.WriteString("127.0.0.1\tlocalhost\n")
+	b.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+	b.WriteString("fe00::0\tip6-localnet\n")
+	b.WriteString("fe00::0\tip6-mcastprefix\n")
+	b.WriteString("fe00::1\tip6-allnodes\n")
+	b.WriteString("fe00::2\tip6-allrouters\n")
+	hostname := p.podHostname(pod)
+	if pod.Spec.Subdomain != "" && !pod.Spec.HostNetwork {
+		domain := p.clusterDomain
+		if domain == "" {
+			domain = "cluster.local"
+		}
+		fqdn := fmt.Sprintf("%s.%s.%s.svc.%s", hostname, pod.Spec.Subdomain, pod.Namespace, domain)
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", pod.Status.PodIP, fqdn, hostname)
+	} else {
+		fmt.Fprintf(&b, "%s\t%s\n", pod.Status.PodIP, hostname)
+	}
+	if len(pod.Spec.HostAliases) > 0 {
+		b.WriteString("\n# Entries added by HostAliases.\n")
+		for _, alias := range pod.Spec.HostAliases {
+			fmt.Fprintf(&b, "%s\t%s\n", alias.IP, strings.Join(alias.Hostnames, "\t"))
+		}
+	}
+	return b.String()
+}
+
+// execSession writes the output of a command to the streams of an exec session. With a
+// TTY, stdout and stderr share the terminal, which translates newlines, and the size of
+// the terminal follows the resize events of the client.
+type execSession struct {
+	attach api.AttachIO
+	tty    bool
+
+	mu   sync.Mutex
+	size *api.TermSize
+	// sized is closed once the client sent the size of the terminal.
+	sized chan struct{}
+}
+
+func newExecSession(ctx context.Context, attach api.AttachIO) *execSession {
+	s := &execSession{attach: attach, tty: attach.TTY(), sized: make(chan struct{})}
+	if resize := attach.Resize(); s.tty && resize != nil {
+		// Resize events must be drained until the session ends, or the API server blocks
+		// sending them.
+		go func() {
+			for {
+				select {
+				case size, ok := <-resize:
+					if !ok {
+						return
+					}
+					s.mu.Lock()
+					if s.size == nil {
+						close(s.sized)
+					}
+					s.size = &size
+					s.mu.Unlock()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return s
+}
+
+// termSize returns the size of the terminal, waiting a little for the client to send it.
+// It reports false without a TTY.
+func (s *execSession) termSize(ctx context.Context) (api.TermSize, bool) {
+	if !s.tty {
+		return api.TermSize{}, false
+	}
+	t := time.NewTimer(termSizeWait)
+	defer t.Stop()
+	select {
+	case <-s.sized:
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size == nil {
+		return api.TermSize{Width: 80, Height: 24}, true
+	}
+	return *s.size, true
+}
+
+func (s *execSession) stdout(data string) {
+	s.write(s.attach.Stdout(), data)
+}
+
+func (s *execSession) stderr(data string) {
+	if s.tty {
+		s.write(s.attach.Stdout(), data)
+		return
+	}
+	s.write(s.attach.Stderr(), data)
+}
+
+func (s *execSession) write(w io.Writer, data string) {
+	if w == nil || data == "" {
+		return
+	}
+	if s.tty {
+		data = strings.Replace(data, "\n", "\r\n", -1)
+	}
+	io.WriteString(w, data) //nolint:errcheck
+}
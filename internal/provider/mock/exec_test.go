@@ -0,0 +1,120 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	v1 "k8s.io/api/core/v1"
+)
+
+type nopCloser struct{ bytes.Buffer }
+
+func (*nopCloser) Close() error { return nil }
+
+// fakeAttach records the output of a command.
+type fakeAttach struct {
+	stdout, stderr nopCloser
+	tty            bool
+	resize         chan api.TermSize
+}
+
+func (a *fakeAttach) Stdin() io.Reader { return nil }
+
+func (a *fakeAttach) Stdout() io.WriteCloser { return &a.stdout }
+
+func (a *fakeAttach) Stderr() io.WriteCloser {
+	if a.tty {
+		return nil
+	}
+	return &a.stderr
+}
+
+func (a *fakeAttach) TTY() bool { return a.tty }
+
+func (a *fakeAttach) Resize() <-chan api.TermSize { return a.resize }
+
+func TestRunInContainer(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{Exec: ExecConfig{Rules: []ExecRule{
+		{Command: "/healthz.sh *", Image: "nginx:*", Stdout: "ok\n"},
+		{Command: "/healthz.sh *", Stderr: "upstream down\n", ExitCode: 3, Latency: Distribution{Type: distributionFixed, Value: 20 * time.Millisecond}},
+	}}}})
+	defer stopPods(p)
+	p.clusterDomain = "cluster.local"
+	pod := newTestPod("web", "app", "proxy")
+	pod.Spec.Hostname = "web-0"
+	pod.Spec.Subdomain = "web"
+	pod.Spec.HostAliases = []v1.HostAlias{{IP: "10.1.2.3", Hostnames: []string{"db", "db.local"}}}
+	pod.Spec.Containers[0].Env = []v1.EnvVar{{Name: "MODE", Value: "prod"}, {Name: "DB_HOST", Value: "db"}}
+	pod.Spec.Containers[1].Image = "nginx:1.19"
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	running := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "web" && pod.Status.Phase == v1.PodRunning })
+
+	run := func(container string, tty bool, cmd ...string) (*fakeAttach, error) {
+		a := &fakeAttach{tty: tty}
+		err := p.RunInContainer(context.Background(), "default", "web", container, cmd, a)
+		return a, err
+	}
+
+	// Rules are matched against the command and the image.
+	a, err := run("proxy", false, "/healthz.sh", "--verbose")
+	if err != nil || a.stdout.String() != "ok\n" {
+		t.Fatalf("expected the scripted output, got %q and %v", a.stdout.String(), err)
+	}
+	start := time.Now()
+	a, err = run("app", false, "/healthz.sh", "--verbose")
+	if exitErr, ok := err.(exitError); !ok || exitErr.ExitStatus() != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+	if a.stderr.String() != "upstream down\n" || time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("expected the scripted error after the latency, got %q after %s", a.stderr.String(), time.Since(start))
+	}
+
+	// Built-in commands.
+	a, _ = run("app", false, "env")
+	if want := "PATH=" + execPath + "\nHOSTNAME=web-0\nDB_HOST=db\nMODE=prod\nHOME=/root\n"; a.stdout.String() != want {
+		t.Fatalf("expected the environment %q, got %q", want, a.stdout.String())
+	}
+	a, _ = run("app", false, "/bin/hostname")
+	if a.stdout.String() != "web-0\n" {
+		t.Fatalf("expected the hostname, got %q", a.stdout.String())
+	}
+	a, _ = run("app", false, "cat", "/etc/hosts")
+	hosts := a.stdout.String()
+	if !strings.Contains(hosts, running.Status.PodIP+"\tweb-0.web.default.svc.cluster.local\tweb-0\n") ||
+		!strings.HasSuffix(hosts, "# Entries added by HostAliases.\n10.1.2.3\tdb\tdb.local\n") {
+		t.Fatalf("unexpected hosts file %q", hosts)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.RunInContainer(ctx, "default", "web", "app", []string{"sleep", "1m"}, &fakeAttach{}); err != context.DeadlineExceeded {
+		t.Fatalf("expected sleep to stop with the session, got %v", err)
+	}
+	if a, err = run("app", false, "curl", "localhost"); err == nil || !strings.Contains(a.stderr.String(), "executable file not found") {
+		t.Fatalf("expected unknown commands to fail, got %q and %v", a.stderr.String(), err)
+	}
+
+	// With a TTY, the output goes to the terminal and follows its size.
+	a = &fakeAttach{tty: true, resize: make(chan api.TermSize, 1)}
+	a.resize <- api.TermSize{Width: 120, Height: 40}
+	if err := p.RunInContainer(context.Background(), "default", "web", "app", []string{"stty", "size"}, a); err != nil {
+		t.Fatal(err)
+	}
+	if a.stdout.String() != "40 120\r\n" {
+		t.Fatalf("expected the size of the terminal, got %q", a.stdout.String())
+	}
+	if a, _ = run("app", true, "sleep", "forever"); a.stdout.String() != "sleep: invalid time interval 'forever'\r\n" {
+		t.Fatalf("expected the error on the terminal, got %q", a.stdout.String())
+	}
+
+	if _, err := run("db", false, "env"); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected the container not to be found, got %v", err)
+	}
+}
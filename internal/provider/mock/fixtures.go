@@ -0,0 +1,246 @@
+package mock
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fixtureTimeKeys are the fields holding the time of the lines of JSONL fixtures, in order
+// of preference.
+var fixtureTimeKeys = []string{"time", "timestamp", "ts", "@timestamp"}
+
+// ContainerSelector selects the containers fixtures are replayed for.
+type ContainerSelector struct {
+	// Image is a glob matched against the image of the containers, such as "nginx:*".
+	Image string `yaml:"image,omitempty"`
+	// Selector is a label selector of the pods of the containers. When both are set, both
+	// must match; when neither is, every container matches.
+	Selector string `yaml:"selector,omitempty"`
+
+	image    *regexp.Regexp
+	selector labels.Selector
+}
+
+func (s *ContainerSelector) compile() error {
+	s.image = nil
+	if s.Image != "" {
+		s.image = regexp.MustCompile(globExpr(s.Image))
+	}
+	selector, err := labels.Parse(s.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %v", err)
+	}
+	s.selector = selector
+	return nil
+}
+
+func (s *ContainerSelector) matches(pod *v1.Pod, image string) bool {
+	if s.image != nil && !s.image.MatchString(image) {
+		return false
+	}
+	return s.selector.Matches(labels.Set(pod.Labels))
+}
+
+// firstMatching returns the index of the first of n selectors that matches the container
+// of the pod running image, or -1 if none does.
+func firstMatching(n int, selector func(i int) *ContainerSelector, pod *v1.Pod, image string) int {
+	for i := 0; i < n; i++ {
+		if selector(i).matches(pod, image) {
+			return i
+		}
+	}
+	return -1
+}
+
+// replayCycle returns how long a replay of a fixture whose entries are at offsets takes: it
+// starts over as long after its last entry as entries are apart on average, or gap after it
+// when it has a single one.
+func replayCycle(offsets []time.Duration, gap time.Duration) time.Duration {
+	n := len(offsets)
+	if n > 1 && offsets[n-1] > 0 {
+		gap = offsets[n-1] / time.Duration(n-1)
+	}
+	if gap <= 0 {
+		gap = 1
+	}
+	return offsets[n-1] + gap
+}
+
+// LogFixture replays a log file as the logs of the containers it selects, instead of
+// generating them.
+type LogFixture struct {
+	ContainerSelector `yaml:",inline"`
+	// Path is the log file. Files ending in .jsonl or .ndjson hold a JSON object per line,
+	// with the time of the line in its "time", "timestamp", "ts" or "@timestamp" field.
+	// Other files are plain text, whose lines may start with an RFC3339 timestamp as
+	// written by "kubectl logs --timestamps".
+	Path string `yaml:"path"`
+	// Speed scales the time between the lines: 2 replays them twice as fast. Defaults to 1.
+	Speed float64 `yaml:"speed,omitempty"`
+
+	fixture *logFixture
+}
+
+// compile validates the fixture and loads its file.
+func (f *LogFixture) compile(rate float64) error {
+	if f.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if f.Speed < 0 {
+		return fmt.Errorf("negative speed")
+	}
+	if err := f.ContainerSelector.compile(); err != nil {
+		return err
+	}
+	speed := f.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	var err error
+	f.fixture, err = loadLogFixture(f.Path, speed, rate)
+	return err
+}
+
+// fixtureFor returns the fixture replayed as the logs of the container running image, or
+// nil if its logs are generated. The first matching fixture applies.
+func (c LogsConfig) fixtureFor(pod *v1.Pod, image string) *logFixture {
+	i := firstMatching(len(c.Fixtures), func(i int) *ContainerSelector { return &c.Fixtures[i].ContainerSelector }, pod, image)
+	if i < 0 {
+		return nil
+	}
+	return c.Fixtures[i].fixture
+}
+
+// logFixture is a log file ready to be replayed. Its lines are written at their offset
+// from the first line, and once the last one is written the file starts over.
+type logFixture struct {
+	lines   []string
+	offsets []time.Duration
+	// cycle is how long a replay of the whole file takes.
+	cycle time.Duration
+}
+
+// loadLogFixture reads the log file at path. The time between its lines is divided by
+// speed. Lines without a time follow the previous one by 1/rate, or a second without a rate.
+func loadLogFixture(path string, speed, rate float64) (*logFixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	jsonLines := ext == ".jsonl" || ext == ".ndjson"
+	step := time.Second
+	if rate > 0 {
+		step = time.Duration(float64(time.Second) / rate)
+	}
+
+	fixture := &logFixture{}
+	var prev time.Time
+	var offset time.Duration
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var t time.Time
+		if jsonLines {
+			if t, err = jsonLineTime(line); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+		} else {
+			t, line = plainLineTime(line)
+		}
+		switch {
+		case len(fixture.lines) == 0:
+		case t.IsZero() || prev.IsZero() || t.Before(prev):
+			// Lines are replayed in order, whatever their time says.
+			offset += step
+		default:
+			offset += t.Sub(prev)
+		}
+		if !t.IsZero() {
+			prev = t
+		}
+		fixture.lines = append(fixture.lines, line)
+		fixture.offsets = append(fixture.offsets, time.Duration(float64(offset)/speed))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(fixture.lines) == 0 {
+		return nil, fmt.Errorf("%s: no log lines", path)
+	}
+
+	fixture.cycle = replayCycle(fixture.offsets, time.Duration(float64(step)/speed))
+	return fixture, nil
+}
+
+// jsonLineTime returns the time of a line of a JSONL fixture, or the zero time if it has
+// none.
+func jsonLineTime(line string) (time.Time, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return time.Time{}, fmt.Errorf("invalid JSON line: %v", err)
+	}
+	for _, key := range fixtureTimeKeys {
+		switch v := fields[key].(type) {
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t, nil
+			}
+		case float64:
+			// Seconds since the epoch.
+			return time.Unix(0, int64(v*float64(time.Second))), nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// plainLineTime splits the timestamp the line starts with, if any, from the rest of it.
+func plainLineTime(line string) (time.Time, string) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return time.Time{}, line
+	}
+	t, err := time.Parse(time.RFC3339Nano, line[:i])
+	if err != nil {
+		return time.Time{}, line
+	}
+	return t, line[i+1:]
+}
+
+// count returns the number of lines written elapsed into the replay.
+func (f *logFixture) count(elapsed time.Duration) int64 {
+	if elapsed < 0 {
+		return 0
+	}
+	cycles := int64(elapsed / f.cycle)
+	within := elapsed % f.cycle
+	k := sort.Search(len(f.offsets), func(i int) bool { return f.offsets[i] > within })
+	return cycles*int64(len(f.lines)) + int64(k)
+}
+
+// offset returns when line i is written into the replay.
+func (f *logFixture) offset(i int64) time.Duration {
+	n := int64(len(f.lines))
+	return time.Duration(i/n)*f.cycle + f.offsets[i%n]
+}
+
+// line returns line i of the replay.
+func (f *logFixture) line(i int64) string {
+	return f.lines[i%int64(len(f.lines))]
+}
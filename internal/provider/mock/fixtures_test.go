@@ -0,0 +1,92 @@
+package mock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VineethReddy02/mocklet/internal/provider"
+	v1 "k8s.io/api/core/v1"
+)
+
+func writeFixture(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLogFixture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := writeFixture(t, dir, "incident.log", "2020-01-01T00:00:00Z starting\n2020-01-01T00:00:02Z upstream timed out\n\nretrying\n")
+	f, err := loadLogFixture(plain, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Twice as fast, and the line without a time follows the previous one by a second.
+	for i, want := range []time.Duration{0, time.Second, 1500 * time.Millisecond, 2250 * time.Millisecond} {
+		if got := f.offset(int64(i)); got != want {
+			t.Fatalf("expected line %d at %s, got %s", i, want, got)
+		}
+	}
+	if f.line(1) != "upstream timed out" || f.line(3) != "starting" {
+		t.Fatalf("expected the timestamps to be stripped and the file to loop, got %q and %q", f.line(1), f.line(3))
+	}
+	for elapsed, want := range map[time.Duration]int64{0: 1, 999 * time.Millisecond: 1, time.Second: 2, 2250 * time.Millisecond: 4} {
+		if got := f.count(elapsed); got != want {
+			t.Fatalf("expected %d lines after %s, got %d", want, elapsed, got)
+		}
+	}
+
+	jsonl := writeFixture(t, dir, "incident.jsonl", `{"ts": "2020-01-01T00:00:00Z", "msg": "a"}`+"\n"+`{"ts": 1577836800.5, "msg": "b"}`+"\n")
+	if f, err = loadLogFixture(jsonl, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if f.offset(1) != 500*time.Millisecond || f.line(0) != `{"ts": "2020-01-01T00:00:00Z", "msg": "a"}` {
+		t.Fatalf("expected JSON lines to be replayed as they are, got %q at %s", f.line(1), f.offset(1))
+	}
+	if _, err := loadLogFixture(writeFixture(t, dir, "broken.jsonl", "not json\n"), 1, 0); err == nil {
+		t.Fatal("expected lines that are not JSON to be rejected")
+	}
+}
+
+func TestLogFixtureReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeFixture(t, dir, "nginx.log", "2020-01-01T00:00:00.000Z GET /\n2020-01-01T00:00:00.010Z GET /healthz\n")
+
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{Logs: LogsConfig{
+		Fixtures: []LogFixture{{ContainerSelector: ContainerSelector{Image: "nginx:*"}, Path: path}},
+	}}})
+	defer stopPods(p)
+	pod := newTestPod("web", "app")
+	pod.Spec.Containers[0].Image = "nginx:1.19"
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "web" && pod.Status.Phase == v1.PodRunning })
+	time.Sleep(50 * time.Millisecond)
+
+	logs := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: -1})
+	if len(logs) < 4 {
+		t.Fatalf("expected the fixture to loop, got %q", logs)
+	}
+	for i, line := range logs {
+		if want := []string{"GET /", "GET /healthz"}[i%2]; line != want {
+			t.Fatalf("expected line %d to be %q, got %q", i, want, line)
+		}
+	}
+}
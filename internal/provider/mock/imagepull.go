@@ -0,0 +1,194 @@
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons reported by the kubelet for containers whose image cannot be pulled.
+const (
+	reasonErrImagePull      = "ErrImagePull"
+	reasonImagePullBackOff  = "ImagePullBackOff"
+	reasonErrImageNeverPull = "ErrImageNeverPull"
+)
+
+// podSyncDelay is how long the kubelet takes to report a change it only notices on its
+// next pod sync, such as a failed pull turning into a back-off. A variable so tests can
+// shorten it.
+var podSyncDelay = time.Second
+
+// ImagePullConfig simulates pulling container images onto the node.
+type ImagePullConfig struct {
+	// Duration is how long pulling an image that is not on the node yet takes.
+	Duration Distribution `yaml:"duration,omitempty"`
+	// Rules make pulls of matching images fail. The first matching rule applies.
+	Rules []ImagePullRule `yaml:"rules,omitempty"`
+}
+
+// ImagePullRule makes the pulls of matching images fail.
+type ImagePullRule struct {
+	// Image is a glob matched against the whole image reference, such as "*:broken" or
+	// "registry.example.com/*". "*" matches any sequence of characters, "?" a single one.
+	Image string `yaml:"image,omitempty"`
+	// Regex is a regular expression matched against the image reference.
+	Regex string `yaml:"regex,omitempty"`
+	// WithoutPullSecrets restricts the rule to pods that have no imagePullSecrets, to
+	// simulate private registries.
+	WithoutPullSecrets bool `yaml:"withoutPullSecrets,omitempty"`
+	// Probability is the chance, between 0 and 1, that a pull of a matching image fails.
+	// Defaults to 1.
+	Probability float64 `yaml:"probability,omitempty"`
+	// Message is the error reported for the failed pull.
+	Message string `yaml:"message,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// compile validates the configuration and prepares the rules for matching.
+func (c *ImagePullConfig) compile() error {
+	if err := c.Duration.validate(); err != nil {
+		return fmt.Errorf("invalid imagePull.duration: %v", err)
+	}
+	for i := range c.Rules {
+		if err := c.Rules[i].compile(); err != nil {
+			return fmt.Errorf("invalid imagePull.rules[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *ImagePullRule) compile() error {
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability %v is not between 0 and 1", r.Probability)
+	}
+	var exprs []string
+	if r.Image != "" {
+		exprs = append(exprs, globExpr(r.Image))
+	}
+	if r.Regex != "" {
+		exprs = append(exprs, "(?:"+r.Regex+")")
+	}
+	if len(exprs) == 0 {
+		return fmt.Errorf("one of image or regex is required")
+	}
+	re, err := regexp.Compile(strings.Join(exprs, "|"))
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+// globExpr returns a regular expression matching the whole of what the glob matches: "*"
+// matches any sequence of characters, "?" a single one.
+func globExpr(glob string) string {
+	expr := regexp.QuoteMeta(glob)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	return "^" + expr + "$"
+}
+
+func (r *ImagePullRule) matches(pod *v1.Pod, image string) bool {
+	if r.WithoutPullSecrets && len(pod.Spec.ImagePullSecrets) > 0 {
+		return false
+	}
+	return r.re.MatchString(image)
+}
+
+// pullError returns the error of a pull of image by the pod, or "" if the pull succeeds.
+func (c ImagePullConfig) pullError(pod *v1.Pod, image string) string {
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if !r.matches(pod, image) {
+			continue
+		}
+		if r.Probability > 0 && rand.Float64() >= r.Probability {
+			return ""
+		}
+		if r.Message != "" {
+			return r.Message
+		}
+		return fmt.Sprintf("rpc error: code = Unknown desc = Error response from daemon: manifest for %s not found", image)
+	}
+	return ""
+}
+
+// startContainer pulls the container's image if needed and then runs the container.
+// Failed pulls leave the container waiting in ErrImagePull and then ImagePullBackOff,
+// and are retried with the kubelet's back-off. Pulled images are kept on the node, so
+// pods with the IfNotPresent policy only pull them once. Containers with the Never policy
+// whose image is not on the node wait in ErrImageNeverPull, checked again with the same
+// back-off until another pod pulls the image.
+func (p *MockProvider) startContainer(mp *mockPod, ref containerRef, now metav1.Time) {
+	pod := mp.pod
+	container := ref.container(pod)
+	image, policy := container.Image, container.ImagePullPolicy
+	cs := ref.status(pod)
+	pull := mp.behavior.ImagePull
+
+	_, present := p.images[image]
+	if policy == v1.PullNever && !present {
+		cs.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{
+			Reason:  reasonErrImageNeverPull,
+			Message: fmt.Sprintf("Container image %q is not present with pull policy of Never", image),
+		}}
+		syncPodStatus(pod, now)
+		p.retryImage(mp, ref, image, now)
+		return
+	}
+	if present && policy != v1.PullAlways {
+		p.runContainer(mp, ref, now)
+		return
+	}
+
+	if message := pull.pullError(pod, image); message != "" {
+		cs.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reasonErrImagePull, Message: message}}
+		syncPodStatus(pod, now)
+		p.after(mp, podSyncDelay, func(now metav1.Time) bool {
+			cs := ref.status(pod)
+			if cs.State.Waiting == nil || cs.State.Waiting.Reason != reasonErrImagePull {
+				return false
+			}
+			cs.State.Waiting = &v1.ContainerStateWaiting{
+				Reason:  reasonImagePullBackOff,
+				Message: fmt.Sprintf("Back-off pulling image %q", image),
+			}
+			syncPodStatus(pod, now)
+			return true
+		})
+		p.retryImage(mp, ref, image, now)
+		return
+	}
+
+	cs.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reasonContainerCreating}}
+	syncPodStatus(pod, now)
+	d := pull.Duration.Sample()
+	if present {
+		d = 0
+	}
+	p.after(mp, d, func(now metav1.Time) bool {
+		if _, present := p.images[image]; !present {
+			p.images[image] = struct{}{}
+			p.notifyNodeStatus()
+		}
+		p.runContainer(mp, ref, now)
+		return true
+	})
+}
+
+// retryImage starts the container again after the back-off of its image. Like the kubelet,
+// the first retry already waits for the initial back-off.
+func (p *MockProvider) retryImage(mp *mockPod, ref containerRef, image string, now metav1.Time) {
+	b := mp.backOff("image/" + image)
+	b.next(now.Time)
+	p.after(mp, b.delay, func(now metav1.Time) bool {
+		p.startContainer(mp, ref, now)
+		return true
+	})
+}
@@ -0,0 +1,268 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultPodCIDR is the range pod IPs are allocated from when neither the configuration
+// nor the node sets one.
+const defaultPodCIDR = "10.244.0.0/16"
+
+// What to do when the pod CIDR has no IP left for a new pod.
+const (
+	// exhaustionWait leaves the pod in ContainerCreating, like the kubelet does when the
+	// CNI plugin fails to set up the pod sandbox, until an IP is released.
+	exhaustionWait = "wait"
+	// exhaustionReject fails the creation of the pod.
+	exhaustionReject = "reject"
+)
+
+// NetworkConfig describes how pods are addressed.
+type NetworkConfig struct {
+	// PodCIDR is the range pod IPs are allocated from. Defaults to the node's
+	// spec.podCIDR, or to 10.244.0.0/16. It should not overlap with other nodes'.
+	PodCIDR string `yaml:"podCIDR,omitempty"`
+	// PodCIDRs would make pods dual-stack, but the Kubernetes API mocklet is built against
+	// has no status.podIPs nor spec.podCIDRs, so setting it is an error rather than being
+	// ignored.
+	PodCIDRs []string `yaml:"podCIDRs,omitempty"`
+	// SecondaryInternalIP is the InternalIP of the node in the other IP family than the
+	// one mocklet is started with, for dual-stack nodes. Pods stay single-stack.
+	SecondaryInternalIP string `yaml:"secondaryInternalIP,omitempty"`
+	// OnExhaustion is what happens to pods created once every IP of the range is in use:
+	// "wait" (the default) or "reject".
+	OnExhaustion string `yaml:"onExhaustion,omitempty"`
+}
+
+// validate checks the configuration of a node whose primary InternalIP is internalIP.
+func (c NetworkConfig) validate(internalIP string) error {
+	if len(c.PodCIDRs) > 0 {
+		return fmt.Errorf("network.podCIDRs is not supported: dual-stack pods need status.podIPs, which the Kubernetes API mocklet is built against does not have")
+	}
+	if c.PodCIDR != "" {
+		if _, _, err := net.ParseCIDR(c.PodCIDR); err != nil {
+			return fmt.Errorf("invalid pod CIDR: %v", err)
+		}
+	}
+	if c.SecondaryInternalIP != "" {
+		secondary := net.ParseIP(c.SecondaryInternalIP)
+		if secondary == nil {
+			return fmt.Errorf("invalid network.secondaryInternalIP %q", c.SecondaryInternalIP)
+		}
+		if primary := net.ParseIP(internalIP); primary != nil && (primary.To4() != nil) == (secondary.To4() != nil) {
+			return fmt.Errorf("network.secondaryInternalIP %s is of the same IP family as the InternalIP %s", c.SecondaryInternalIP, internalIP)
+		}
+	}
+	switch c.OnExhaustion {
+	case "", exhaustionWait, exhaustionReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid network.onExhaustion %q, must be %s or %s", c.OnExhaustion, exhaustionWait, exhaustionReject)
+	}
+}
+
+// ipPool hands out the IPs of a CIDR, one per pod. Like the host-local CNI plugin, it
+// allocates IPs in order, skipping the network, gateway and broadcast addresses, and
+// only reuses released IPs once it has gone through the whole range.
+type ipPool struct {
+	cidr  *net.IPNet
+	first *big.Int
+	size  *big.Int
+	next  *big.Int
+	used  map[string]struct{}
+}
+
+func newIPPool(cidr string) (*ipPool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	first := new(big.Int).SetBytes(ipNet.IP)
+	// Leave out the network address and the gateway, and the broadcast address for IPv4.
+	reserved := int64(2)
+	if ipNet.IP.To4() != nil {
+		reserved = 3
+	}
+	if size.Cmp(big.NewInt(reserved)) > 0 {
+		first.Add(first, big.NewInt(2))
+		size.Sub(size, big.NewInt(reserved))
+	}
+	return &ipPool{
+		cidr:  ipNet,
+		first: first,
+		size:  size,
+		next:  big.NewInt(0),
+		used:  make(map[string]struct{}),
+	}, nil
+}
+
+// allocate returns a free IP of the pool.
+func (p *ipPool) allocate() (string, error) {
+	if big.NewInt(int64(len(p.used))).Cmp(p.size) >= 0 {
+		return "", fmt.Errorf("no IP addresses available in range %s", p.cidr)
+	}
+	one := big.NewInt(1)
+	for {
+		offset := new(big.Int).Set(p.next)
+		p.next.Add(p.next, one)
+		if p.next.Cmp(p.size) >= 0 {
+			p.next.SetInt64(0)
+		}
+		ip := p.ip(offset).String()
+		if _, used := p.used[ip]; !used {
+			p.used[ip] = struct{}{}
+			return ip, nil
+		}
+	}
+}
+
+func (p *ipPool) ip(offset *big.Int) net.IP {
+	n := new(big.Int).Add(p.first, offset).Bytes()
+	ip := make(net.IP, len(p.cidr.IP))
+	copy(ip[len(ip)-len(n):], n)
+	return ip
+}
+
+// reserve marks an IP of the pool as used, and reports whether it was free.
+func (p *ipPool) reserve(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || !p.cidr.Contains(parsed) {
+		return false
+	}
+	if v4 := parsed.To4(); v4 != nil && len(p.cidr.IP) == net.IPv4len {
+		parsed = v4
+	}
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(parsed), p.first)
+	if offset.Sign() < 0 || offset.Cmp(p.size) >= 0 {
+		return false
+	}
+	key := p.ip(offset).String()
+	if _, used := p.used[key]; used {
+		return false
+	}
+	p.used[key] = struct{}{}
+	return true
+}
+
+func (p *ipPool) release(ip string) {
+	delete(p.used, ip)
+}
+
+// assignPodIP allocates the IP of the pod when its sandbox is created, and reports whether
+// it got one. Without a free IP the pod stays in ContainerCreating and the allocation is
+// retried with the kubelet's back-off.
+func (p *MockProvider) assignPodIP(mp *mockPod, now metav1.Time) bool {
+	if mp.podIP == "" {
+		ip, err := p.ipPool.allocate()
+		if err != nil {
+			setWaitingMessage(mp.pod, fmt.Sprintf("failed to set up pod sandbox network: %v", err))
+			b := mp.backOff("sandbox")
+			b.next(now.Time)
+			p.after(mp, b.delay, func(now metav1.Time) bool {
+				return p.createSandbox(mp, now)
+			})
+			return false
+		}
+		mp.podIP = ip
+		setWaitingMessage(mp.pod, "")
+	}
+	mp.pod.Status.PodIP = mp.podIP
+	return true
+}
+
+// setWaitingMessage sets the message of the app containers waiting for the pod sandbox.
+func setWaitingMessage(pod *v1.Pod, message string) {
+	for i := range pod.Status.ContainerStatuses {
+		if w := pod.Status.ContainerStatuses[i].State.Waiting; w != nil {
+			w.Message = message
+		}
+	}
+}
+
+// restorePodIP gives a restored pod its IP back. An IP that is outside the pod CIDR or
+// already in use is replaced by a new one. It reports whether the pod got an IP.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) restorePodIP(mp *mockPod, ip string) bool {
+	if !p.ipPool.reserve(ip) {
+		var err error
+		if ip, err = p.ipPool.allocate(); err != nil {
+			return false
+		}
+	}
+	mp.podIP = ip
+	mp.pod.Status.PodIP = ip
+	return true
+}
+
+// releasePodIP returns the IP of the pod to the pool.
+func (p *MockProvider) releasePodIP(mp *mockPod) {
+	if mp.podIP != "" {
+		p.ipPool.release(mp.podIP)
+		mp.podIP = ""
+	}
+}
+
+// configurePodCIDR sets up the pool of pod IPs when the node is configured: the node
+// advertises the configured pod CIDR, or the pool uses the node's if there is none.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) configurePodCIDR(ctx context.Context, n *v1.Node) {
+	if p.config.Network.PodCIDR != "" {
+		n.Spec.PodCIDR = p.config.Network.PodCIDR
+		return
+	}
+	if err := p.adoptPodCIDR(n.Spec.PodCIDR); err != nil {
+		log.G(ctx).WithError(err).Warn("Ignoring the pod CIDR of the node")
+	}
+}
+
+// UpdateNode follows the changes made to the node after it is registered. The node IPAM
+// controller assigns spec.podCIDR once the node exists, so the pool of pod IPs switches to
+// it then, unless the pod CIDR is configured.
+func (p *MockProvider) UpdateNode(ctx context.Context, n *v1.Node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.config.Network.PodCIDR != "" || p.node == nil {
+		return
+	}
+	if err := p.adoptPodCIDR(n.Spec.PodCIDR); err != nil {
+		log.G(ctx).WithError(err).Warn("Ignoring the pod CIDR of the node")
+		return
+	}
+	p.node.Spec.PodCIDR = n.Spec.PodCIDR
+}
+
+// adoptPodCIDR makes pods get their IPs from the CIDR, if it is set and differs from the
+// current one. The IPs of the pods in the new range stay theirs; pods outside of it keep
+// their IPs until they are deleted, like on a node whose CIDR changed under the kubelet.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) adoptPodCIDR(cidr string) error {
+	if cidr == "" {
+		return nil
+	}
+	pool, err := newIPPool(cidr)
+	if err != nil {
+		return err
+	}
+	if pool.cidr.String() == p.ipPool.cidr.String() {
+		return nil
+	}
+	for _, mp := range p.pods {
+		if mp.podIP != "" {
+			pool.reserve(mp.podIP)
+		}
+	}
+	p.ipPool = pool
+	return nil
+}
@@ -0,0 +1,181 @@
+package mock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestIPPool(t *testing.T) {
+	pool, err := newIPPool("10.0.0.0/29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ips []string
+	for i := 0; i < 5; i++ {
+		ip, err := pool.allocate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ips = append(ips, ip)
+	}
+	if got := strings.Join(ips, " "); got != "10.0.0.2 10.0.0.3 10.0.0.4 10.0.0.5 10.0.0.6" {
+		t.Fatalf("unexpected IPs %s", got)
+	}
+	if _, err := pool.allocate(); err == nil {
+		t.Fatal("expected the pool to be exhausted")
+	}
+
+	pool.release("10.0.0.4")
+	if ip, err := pool.allocate(); err != nil || ip != "10.0.0.4" {
+		t.Fatalf("expected the released IP to be reused, got %s, %v", ip, err)
+	}
+
+	pool, err = newIPPool("fd00::/120")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip, err := pool.allocate(); err != nil || ip != "fd00::2" {
+		t.Fatalf("unexpected IPv6 allocation %s, %v", ip, err)
+	}
+}
+
+func TestPodIPExhaustion(t *testing.T) {
+	defer func(initial time.Duration) { backOffInitial = initial }(backOffInitial)
+	backOffInitial = 50 * time.Millisecond
+
+	p, ch := newTestProvider(t, MockConfig{Network: NetworkConfig{PodCIDR: "10.0.0.0/30"}})
+	defer stopPods(p)
+
+	first := newTestPod("first", "nginx")
+	if err := p.CreatePod(context.Background(), first.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	pod := waitForPod(t, ch, isReady)
+	if pod.Status.PodIP != "10.0.0.2" || pod.Status.HostIP != "10.0.0.1" {
+		t.Fatalf("unexpected pod IP %q and host IP %q", pod.Status.PodIP, pod.Status.HostIP)
+	}
+
+	if err := p.CreatePod(context.Background(), newTestPod("second", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool {
+		w := pod.Status.ContainerStatuses[0].State.Waiting
+		return pod.Name == "second" && w != nil && strings.Contains(w.Message, "no IP addresses available")
+	})
+	if pod.Status.PodIP != "" {
+		t.Fatalf("expected no pod IP, got %s", pod.Status.PodIP)
+	}
+
+	if err := p.DeletePod(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "second" && isReady(pod) })
+	if pod.Status.PodIP != "10.0.0.2" {
+		t.Fatalf("expected the released IP to be reused, got %s", pod.Status.PodIP)
+	}
+}
+
+func TestPodIPExhaustionReject(t *testing.T) {
+	p, _ := newTestProvider(t, MockConfig{Network: NetworkConfig{PodCIDR: "10.0.0.0/30", OnExhaustion: exhaustionReject}})
+	defer stopPods(p)
+
+	if err := p.CreatePod(context.Background(), newTestPod("first", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreatePod(context.Background(), newTestPod("second", "nginx")); err == nil {
+		t.Fatal("expected the pod to be rejected")
+	}
+}
+
+func TestSecondaryInternalIP(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Network: NetworkConfig{
+		PodCIDR:             "10.0.0.0/24",
+		SecondaryInternalIP: "fd00::1",
+	}})
+	defer stopPods(p)
+
+	node := &v1.Node{}
+	node.Labels = map[string]string{}
+	p.ConfigureNode(context.Background(), node)
+	var internalIPs []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			internalIPs = append(internalIPs, address.Address)
+		}
+	}
+	if got := strings.Join(internalIPs, " "); got != "10.0.0.1 fd00::1" {
+		t.Fatalf("expected IPv4 and IPv6 internal IPs, got %s", got)
+	}
+
+	// Pods stay single-stack.
+	if err := p.CreatePod(context.Background(), newTestPod("web", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+	if pod := waitForPod(t, ch, isReady); pod.Status.PodIP != "10.0.0.2" {
+		t.Fatalf("unexpected pod IP %s", pod.Status.PodIP)
+	}
+}
+
+func TestNetworkConfigValidation(t *testing.T) {
+	for _, c := range []NetworkConfig{
+		{PodCIDR: "10.0.0.0"},
+		{PodCIDRs: []string{"10.0.0.0/24", "fd00:10::/64"}},
+		{SecondaryInternalIP: "localhost"},
+		{SecondaryInternalIP: "10.0.0.2"},
+		{OnExhaustion: "panic"},
+	} {
+		if err := c.validate("10.0.0.1"); err == nil {
+			t.Fatalf("expected %+v to be rejected", c)
+		}
+	}
+	if err := (NetworkConfig{SecondaryInternalIP: "fd00::1"}).validate("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPodCIDRAssignedAfterRegistration(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+
+	node := &v1.Node{}
+	node.Labels = map[string]string{}
+	p.ConfigureNode(context.Background(), node)
+	if err := p.CreatePod(context.Background(), newTestPod("before", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+	if pod := waitForPod(t, ch, isReady); pod.Status.PodIP != "10.244.0.2" {
+		t.Fatalf("expected an IP of the default range, got %s", pod.Status.PodIP)
+	}
+
+	// The node IPAM controller assigns the CIDR once the node is registered.
+	registered := node.DeepCopy()
+	registered.Spec.PodCIDR = "10.10.1.0/24"
+	p.UpdateNode(context.Background(), registered)
+	if err := p.CreatePod(context.Background(), newTestPod("after", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+	if pod := waitForPod(t, ch, isReady); pod.Status.PodIP != "10.10.1.2" {
+		t.Fatalf("expected an IP of the assigned range, got %s", pod.Status.PodIP)
+	}
+
+	// The pod started before keeps its IP.
+	before, err := p.GetPod(context.Background(), "default", "before")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Status.PodIP != "10.244.0.2" {
+		t.Fatalf("expected the first pod to keep its IP, got %s", before.Status.PodIP)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ip := p.pods["default-before"].podIP; ip != "10.244.0.2" {
+		t.Fatalf("expected the first pod to keep its IP, got %s", ip)
+	}
+	if cidr := p.nodeStatus().Spec.PodCIDR; cidr != "10.10.1.0/24" {
+		t.Fatalf("expected the node to keep the assigned CIDR, got %s", cidr)
+	}
+}
@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// JobConfig makes the app containers of pods owned by a Job, including the ones created
+// for CronJobs, run to completion instead of running forever.
+type JobConfig struct {
+	// Duration is how long each container runs before exiting. When unset, Job pods run forever.
+	Duration Distribution `yaml:"duration,omitempty"`
+	// ExitCode is the exit code of containers that run to completion. Depending on the
+	// restart policy, non-zero exit codes restart the container or fail the pod.
+	ExitCode int32 `yaml:"exitCode,omitempty"`
+}
+
+func (c JobConfig) validate() error {
+	if err := c.Duration.validate(); err != nil {
+		return fmt.Errorf("invalid job.duration: %v", err)
+	}
+	return nil
+}
+
+// exitReason is the termination reason the kubelet reports for the exit code.
+func (c JobConfig) exitReason() string {
+	if c.ExitCode == 0 {
+		return reasonCompleted
+	}
+	return reasonError
+}
+
+// isJobPod reports whether the pod is controlled by a Job.
+func isJobPod(pod *v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "Job" && ref.APIVersion == "batch/v1" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package mock
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Supported values for Distribution.Type.
+const (
+	distributionFixed     = "fixed"
+	distributionUniform   = "uniform"
+	distributionNormal    = "normal"
+	distributionLogNormal = "lognormal"
+	distributionHistogram = "histogram"
+)
+
+// Distribution describes a random duration, such as the time a pod takes to start.
+//
+// A distribution can be written in the provider config either as a plain duration
+// ("5s"), which is a fixed distribution, or as a map selecting one of the supported types:
+//
+//	fixed:     {type: fixed, value: 5s}
+//	uniform:   {type: uniform, min: 1s, max: 10s}
+//	normal:    {type: normal, mean: 5s, stddev: 1s}
+//	lognormal: {type: lognormal, median: 5s, sigma: 0.5}
+//	histogram: {type: histogram, buckets: [{le: 1s, weight: 9}, {le: 30s, weight: 1}]}
+//
+// Min and Max, when set, clamp the samples of every type. The zero value always yields 0.
+type Distribution struct {
+	Type    string            `yaml:"type,omitempty"`
+	Value   time.Duration     `yaml:"value,omitempty"`
+	Min     time.Duration     `yaml:"min,omitempty"`
+	Max     time.Duration     `yaml:"max,omitempty"`
+	Mean    time.Duration     `yaml:"mean,omitempty"`
+	StdDev  time.Duration     `yaml:"stddev,omitempty"`
+	Median  time.Duration     `yaml:"median,omitempty"`
+	Sigma   float64           `yaml:"sigma,omitempty"`
+	Buckets []HistogramBucket `yaml:"buckets,omitempty"`
+}
+
+// HistogramBucket is one bucket of an empirical histogram. Samples falling in the bucket
+// are spread uniformly between the previous bucket's upper bound and LE.
+type HistogramBucket struct {
+	LE     time.Duration `yaml:"le"`
+	Weight float64       `yaml:"weight"`
+}
+
+// distribution is used to decode the map form of a Distribution without recursing
+// into Distribution.UnmarshalYAML.
+type distribution Distribution
+
+// UnmarshalYAML accepts either a plain duration or the map form of a distribution.
+func (d *Distribution) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value time.Duration
+	if err := unmarshal(&value); err == nil {
+		*d = Distribution{Type: distributionFixed, Value: value}
+		return nil
+	}
+	var raw distribution
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	*d = Distribution(raw)
+	return nil
+}
+
+// IsZero reports whether the distribution has been left unset.
+func (d Distribution) IsZero() bool {
+	return d.Type == ""
+}
+
+// validate checks that the parameters required by the distribution's type are present.
+func (d Distribution) validate() error {
+	if d.Min < 0 || d.Max < 0 || (d.Max > 0 && d.Min > d.Max) {
+		return fmt.Errorf("invalid bounds [%v, %v]", d.Min, d.Max)
+	}
+	switch d.Type {
+	case "", distributionFixed:
+		if d.Value < 0 {
+			return fmt.Errorf("negative value %v", d.Value)
+		}
+	case distributionUniform:
+		if d.Max == 0 {
+			return fmt.Errorf("uniform distribution requires max")
+		}
+	case distributionNormal:
+		if d.StdDev < 0 {
+			return fmt.Errorf("negative stddev %v", d.StdDev)
+		}
+	case distributionLogNormal:
+		if d.Median <= 0 || d.Sigma < 0 {
+			return fmt.Errorf("lognormal distribution requires a positive median and a non-negative sigma")
+		}
+	case distributionHistogram:
+		if len(d.Buckets) == 0 {
+			return fmt.Errorf("histogram distribution requires buckets")
+		}
+		var prev time.Duration
+		var total float64
+		for _, b := range d.Buckets {
+			if b.LE < prev || b.Weight < 0 {
+				return fmt.Errorf("histogram buckets must be increasing and have non-negative weights")
+			}
+			prev = b.LE
+			total += b.Weight
+		}
+		if total == 0 {
+			return fmt.Errorf("histogram buckets have no weight")
+		}
+	default:
+		return fmt.Errorf("unknown distribution type %q", d.Type)
+	}
+	return nil
+}
+
+// Sample draws a duration from the distribution. Samples are never negative.
+func (d Distribution) Sample() time.Duration {
+	var v float64
+	switch d.Type {
+	case distributionFixed:
+		v = float64(d.Value)
+	case distributionUniform:
+		v = float64(d.Min) + rand.Float64()*float64(d.Max-d.Min)
+	case distributionNormal:
+		v = float64(d.Mean) + rand.NormFloat64()*float64(d.StdDev)
+	case distributionLogNormal:
+		v = float64(d.Median) * math.Exp(rand.NormFloat64()*d.Sigma)
+	case distributionHistogram:
+		v = d.sampleHistogram()
+	}
+
+	if v < float64(d.Min) {
+		v = float64(d.Min)
+	}
+	if d.Max > 0 && v > float64(d.Max) {
+		v = float64(d.Max)
+	}
+	if v < 0 || math.IsNaN(v) {
+		return 0
+	}
+	if v > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(v)
+}
+
+func (d Distribution) sampleHistogram() float64 {
+	var total float64
+	for _, b := range d.Buckets {
+		total += b.Weight
+	}
+	r := rand.Float64() * total
+	var lower time.Duration
+	for _, b := range d.Buckets {
+		if r < b.Weight {
+			return float64(lower) + rand.Float64()*float64(b.LE-lower)
+		}
+		r -= b.Weight
+		lower = b.LE
+	}
+	return float64(lower)
+}
@@ -0,0 +1,73 @@
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestDistributionUnmarshal(t *testing.T) {
+	var cfg StartupConfig
+	data := `
+started: 3s
+ready:
+  type: histogram
+  buckets:
+  - le: 1s
+    weight: 1
+  - le: 2s
+    weight: 3
+`
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Started.Type != distributionFixed || cfg.Started.Value != 3*time.Second {
+		t.Fatalf("unexpected started distribution %+v", cfg.Started)
+	}
+	if len(cfg.Ready.Buckets) != 2 || cfg.Ready.Buckets[1].LE != 2*time.Second {
+		t.Fatalf("unexpected ready distribution %+v", cfg.Ready)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDistributionSample(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		d        Distribution
+		min, max time.Duration
+	}{
+		{"zero", Distribution{}, 0, 0},
+		{"fixed", Distribution{Type: distributionFixed, Value: time.Second}, time.Second, time.Second},
+		{"uniform", Distribution{Type: distributionUniform, Min: time.Second, Max: 2 * time.Second}, time.Second, 2 * time.Second},
+		{"normal", Distribution{Type: distributionNormal, Mean: time.Second, StdDev: time.Second}, 0, time.Hour},
+		{"lognormal", Distribution{Type: distributionLogNormal, Median: time.Second, Sigma: 1, Max: 5 * time.Second}, 0, 5 * time.Second},
+		{"histogram", Distribution{Type: distributionHistogram, Buckets: []HistogramBucket{{LE: time.Second, Weight: 0}, {LE: 2 * time.Second, Weight: 1}}}, time.Second, 2 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.d.validate(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 1000; i++ {
+				if v := tc.d.Sample(); v < tc.min || v > tc.max {
+					t.Fatalf("sample %v out of [%v, %v]", v, tc.min, tc.max)
+				}
+			}
+		})
+	}
+}
+
+func TestDistributionValidate(t *testing.T) {
+	for _, d := range []Distribution{
+		{Type: "poisson"},
+		{Type: distributionUniform},
+		{Type: distributionLogNormal},
+		{Type: distributionHistogram, Buckets: []HistogramBucket{{LE: 2 * time.Second, Weight: 1}, {LE: time.Second, Weight: 1}}},
+	} {
+		if err := d.validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", d)
+		}
+	}
+}
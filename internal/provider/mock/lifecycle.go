@@ -0,0 +1,282 @@
+package mock
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mockPod is the provider's record of a pod. Besides the pod itself it keeps the timers
+// driving the pod's simulated lifecycle, so they can be cancelled when the pod goes away.
+type mockPod struct {
+	pod      *v1.Pod
+	behavior Behavior
+	timers   map[*time.Timer]struct{}
+	backOffs map[string]*backOff
+	deleted  bool
+	podIP    string
+	// stale is set when the status of the pod changed before the pod notifier was set, so
+	// it is pushed once it is.
+	stale bool
+	// mountingSince is when the volumes of the pod started failing to mount.
+	mountingSince time.Time
+	// usageStates carries the usage of the running containers from one sample to the next,
+	// and cpuCoreNanoSeconds is the CPU time used by the container runs that ended.
+	usageStates        map[string]*usageState
+	cpuCoreNanoSeconds uint64
+
+	// terminating is set once the pod has been deleted and its containers are shutting
+	// down, which they must have done by deadline.
+	terminating bool
+	deadline    time.Time
+}
+
+func newMockPod(pod *v1.Pod, behavior Behavior) *mockPod {
+	return &mockPod{
+		pod:      pod,
+		behavior: behavior,
+		timers:   make(map[*time.Timer]struct{}),
+		backOffs: make(map[string]*backOff),
+	}
+}
+
+// containerRef points at one of the pod's containers: an init container if init is set,
+// an app container otherwise. Container statuses are kept in the order of the pod spec.
+type containerRef struct {
+	init  bool
+	index int
+}
+
+func (r containerRef) container(pod *v1.Pod) *v1.Container {
+	if r.init {
+		return &pod.Spec.InitContainers[r.index]
+	}
+	return &pod.Spec.Containers[r.index]
+}
+
+func (r containerRef) status(pod *v1.Pod) *v1.ContainerStatus {
+	if r.init {
+		return &pod.Status.InitContainerStatuses[r.index]
+	}
+	return &pod.Status.ContainerStatuses[r.index]
+}
+
+// stop cancels every pending lifecycle transition of the pod, and prevents new ones.
+func (mp *mockPod) stop() {
+	mp.deleted = true
+	mp.cancel()
+	mp.timers = nil
+}
+
+// cancel cancels every pending lifecycle transition of the pod.
+func (mp *mockPod) cancel() {
+	for t := range mp.timers {
+		t.Stop()
+		delete(mp.timers, t)
+	}
+}
+
+// after runs fn against the pod once d has elapsed. fn is called with the provider lock
+// held and reports whether it changed the pod; if it did, the new status is pushed to the
+// pod notifier. Transitions still pending when the pod is deleted are dropped.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) after(mp *mockPod, d time.Duration, fn func(now metav1.Time) bool) {
+	if mp.deleted {
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if mp.deleted {
+			return
+		}
+		delete(mp.timers, t)
+		if fn(metav1.Now()) {
+			p.notifyPod(mp)
+		}
+	})
+	mp.timers[t] = struct{}{}
+}
+
+// afterRun is like after, but only runs fn if the container is still in the same run, that
+// is it is running and has not been restarted in the meantime.
+func (p *MockProvider) afterRun(mp *mockPod, ref containerRef, d time.Duration, fn func(cs *v1.ContainerStatus, now metav1.Time) bool) {
+	id := ref.status(mp.pod).ContainerID
+	p.after(mp, d, func(now metav1.Time) bool {
+		cs := ref.status(mp.pod)
+		if cs.ContainerID != id || cs.State.Running == nil {
+			return false
+		}
+		return fn(cs, now)
+	})
+}
+
+// notifyPod publishes the pod to the pod store and pushes a copy of it to the notifier set
+// in NotifyPods. Notifying with p.mu held keeps the updates of a pod in order.
+func (p *MockProvider) notifyPod(mp *mockPod) {
+	p.publishPod(mp)
+	if p.notifier == nil {
+		mp.stale = true
+		return
+	}
+	p.notifier(mp.pod.DeepCopy())
+}
+
+// publishPod stores a snapshot of the pod for GetPod and GetPods, unless the provider has
+// forgotten it.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) publishPod(mp *mockPod) {
+	key, err := buildKey(mp.pod)
+	if err == nil && p.pods[key] == mp {
+		p.store.put(mp.pod)
+	}
+}
+
+// startPod resets the pod status to what the kubelet reports right after admitting the
+// pod, and schedules the transitions that bring its containers up: once the pod sandbox
+// is created the init containers run one at a time, then the app containers start in
+// order, each becoming ready on its own.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) startPod(mp *mockPod, now metav1.Time) {
+	pod := mp.pod
+	pod.Status = v1.PodStatus{
+		Phase:     v1.PodPending,
+		HostIP:    p.internalIP,
+		StartTime: &now,
+	}
+	setPodCondition(&pod.Status, v1.PodScheduled, v1.ConditionTrue, "", "", now)
+
+	waitingReason := reasonContainerCreating
+	if len(pod.Spec.InitContainers) > 0 {
+		waitingReason = reasonPodInitializing
+	}
+	for _, container := range pod.Spec.InitContainers {
+		pod.Status.InitContainerStatuses = append(pod.Status.InitContainerStatuses, newContainerStatus(container, reasonPodInitializing))
+	}
+	for _, container := range pod.Spec.Containers {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, newContainerStatus(container, waitingReason))
+	}
+	syncPodStatus(pod, now)
+
+	p.after(mp, mp.behavior.Startup.Started.Sample(), func(now metav1.Time) bool {
+		return p.createSandbox(mp, now)
+	})
+}
+
+// createSandbox mounts the volumes of the pod, sets up the pod sandbox, giving the pod its
+// IP, and starts running the init containers.
+func (p *MockProvider) createSandbox(mp *mockPod, now metav1.Time) bool {
+	retry := func(now metav1.Time) bool {
+		return p.createSandbox(mp, now)
+	}
+	if p.mountVolumes(mp, now, retry) && p.assignPodIP(mp, now) {
+		p.runInitContainer(mp, 0, now)
+	}
+	return true
+}
+
+// newContainerStatus returns the status of a container that has not been created yet.
+func newContainerStatus(container v1.Container, reason string) v1.ContainerStatus {
+	return v1.ContainerStatus{
+		Name:  container.Name,
+		Image: container.Image,
+		State: v1.ContainerState{
+			Waiting: &v1.ContainerStateWaiting{Reason: reason},
+		},
+	}
+}
+
+// runInitContainer starts the i-th init container. Init containers run one at a time: the
+// next one is started when this one completes. Once every init container has completed
+// the app containers are created.
+func (p *MockProvider) runInitContainer(mp *mockPod, i int, now metav1.Time) {
+	pod := mp.pod
+	if i == len(pod.Status.InitContainerStatuses) {
+		for j := range pod.Status.ContainerStatuses {
+			pod.Status.ContainerStatuses[j].State = v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: reasonContainerCreating},
+			}
+		}
+		syncPodStatus(pod, now)
+		p.scheduleContainer(mp, 0)
+		return
+	}
+	p.startContainer(mp, containerRef{init: true, index: i}, now)
+}
+
+// scheduleContainer schedules the i-th app container to start. Containers are started in
+// the order of the pod spec, so the next one is only scheduled once this one is started.
+func (p *MockProvider) scheduleContainer(mp *mockPod, i int) {
+	pod := mp.pod
+	if i == len(pod.Status.ContainerStatuses) {
+		return
+	}
+	p.after(mp, mp.behavior.Startup.Containers.Sample(), func(now metav1.Time) bool {
+		p.startContainer(mp, containerRef{index: i}, now)
+		p.scheduleContainer(mp, i+1)
+		return true
+	})
+}
+
+// runContainer starts a new run of the container and schedules how it ends, unless its
+// configuration cannot be generated.
+func (p *MockProvider) runContainer(mp *mockPod, ref containerRef, now metav1.Time) {
+	if !p.createContainerConfig(mp, ref, now) {
+		return
+	}
+	pod := mp.pod
+	cs := ref.status(pod)
+	cs.ContainerID = RandStringRunes(64)
+	cs.Ready = false
+	cs.State = v1.ContainerState{
+		Running: &v1.ContainerStateRunning{StartedAt: now},
+	}
+	syncPodStatus(pod, now)
+	p.scheduleRun(mp, ref, 0, now)
+}
+
+// scheduleRun schedules how the current run of the container ends, elapsed into the run:
+// init containers complete, app containers are probed and may run to completion or be
+// killed by a failing probe, and either may crash. Whichever exit comes first ends the run.
+func (p *MockProvider) scheduleRun(mp *mockPod, ref containerRef, elapsed time.Duration, now metav1.Time) {
+	b := mp.behavior
+	if crashes, crashAfter := b.Crash.plan(); crashes {
+		p.afterRun(mp, ref, remaining(crashAfter, elapsed), func(cs *v1.ContainerStatus, now metav1.Time) bool {
+			p.exitContainer(mp, ref, b.Crash.exitCode(), b.Crash.reason(), "", now)
+			return true
+		})
+	}
+	if ref.init {
+		p.afterRun(mp, ref, remaining(b.Startup.InitContainers.Sample(), elapsed), func(cs *v1.ContainerStatus, now metav1.Time) bool {
+			p.exitContainer(mp, ref, 0, reasonCompleted, "", now)
+			return true
+		})
+		return
+	}
+
+	// A container that is already ready has passed its startup probe.
+	if ref.status(mp.pod).Ready {
+		p.startedProbes(mp, ref, now)
+	} else {
+		p.startProbes(mp, ref, now)
+	}
+	if !b.Job.Duration.IsZero() {
+		p.afterRun(mp, ref, remaining(b.Job.Duration.Sample(), elapsed), func(cs *v1.ContainerStatus, now metav1.Time) bool {
+			p.exitContainer(mp, ref, b.Job.ExitCode, b.Job.exitReason(), "", now)
+			return true
+		})
+	}
+}
+
+// remaining returns what is left of d once elapsed has passed.
+func remaining(d, elapsed time.Duration) time.Duration {
+	if d < elapsed {
+		return 0
+	}
+	return d - elapsed
+}
@@ -0,0 +1,434 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/VineethReddy02/mocklet/internal/provider"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Formats of the generated logs.
+const (
+	logFormatPlain = "plain"
+	logFormatJSON  = "json"
+)
+
+// Levels of the generated log lines.
+const (
+	logLevelInfo  = "INFO"
+	logLevelError = "ERROR"
+)
+
+// Templates of the generated log lines when none are configured.
+var (
+	defaultLogTemplates      = []string{"handled request {{.Line}}"}
+	defaultErrorLogTemplates = []string{"failed to handle request {{.Line}}: connection reset by peer"}
+)
+
+// followPollInterval is how often followed logs check whether the container is still
+// running. A variable so tests can shorten it.
+var followPollInterval = 100 * time.Millisecond
+
+// LogsConfig makes containers write logs, which are generated on the fly when they are
+// read. The lines of a container run only depend on the run, so reading them again returns
+// the same lines.
+type LogsConfig struct {
+	// Rate is the number of lines each container writes per second. When unset, containers
+	// write no logs.
+	Rate float64 `yaml:"rate,omitempty"`
+	// Format is "plain", the default, for lines as the templates render them, or "json"
+	// for JSON objects with the time, level and message of each line.
+	Format string `yaml:"format,omitempty"`
+	// Templates are the text/template templates of the lines, one of which is picked at
+	// random for each line. They can use the .Namespace, .Pod, .Container, .Line (the
+	// number of the line in the run), .Level and .Time fields.
+	Templates []string `yaml:"templates,omitempty"`
+	// ErrorRatio is the share of lines, between 0 and 1, that are errors.
+	ErrorRatio float64 `yaml:"errorRatio,omitempty"`
+	// ErrorTemplates are the templates of the error lines.
+	ErrorTemplates []string `yaml:"errorTemplates,omitempty"`
+	// Fixtures replay log files as the logs of the containers they select, instead of
+	// generating them. The first matching fixture applies.
+	Fixtures []LogFixture `yaml:"fixtures,omitempty"`
+
+	templates      []*template.Template
+	errorTemplates []*template.Template
+}
+
+// logFields are the fields the log templates can use.
+type logFields struct {
+	Namespace string
+	Pod       string
+	Container string
+	Line      int64
+	Level     string
+	Time      time.Time
+}
+
+// compile validates the configuration and parses the templates.
+func (c *LogsConfig) compile() error {
+	if c.Rate < 0 {
+		return fmt.Errorf("invalid logs.rate: negative rate")
+	}
+	if c.ErrorRatio < 0 || c.ErrorRatio > 1 {
+		return fmt.Errorf("invalid logs.errorRatio: %v is not between 0 and 1", c.ErrorRatio)
+	}
+	switch c.Format {
+	case "", logFormatPlain, logFormatJSON:
+	default:
+		return fmt.Errorf("invalid logs.format %q: must be plain or json", c.Format)
+	}
+	var err error
+	if c.templates, err = compileLogTemplates(c.Templates, defaultLogTemplates); err != nil {
+		return fmt.Errorf("invalid logs.templates: %v", err)
+	}
+	if c.errorTemplates, err = compileLogTemplates(c.ErrorTemplates, defaultErrorLogTemplates); err != nil {
+		return fmt.Errorf("invalid logs.errorTemplates: %v", err)
+	}
+	// Profiles inheriting the fixtures of the node share them until they are compiled.
+	c.Fixtures = append([]LogFixture(nil), c.Fixtures...)
+	for i := range c.Fixtures {
+		if err := c.Fixtures[i].compile(c.Rate); err != nil {
+			return fmt.Errorf("invalid logs.fixtures[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func compileLogTemplates(texts, defaults []string) ([]*template.Template, error) {
+	if len(texts) == 0 {
+		texts = defaults
+	}
+	templates := make([]*template.Template, len(texts))
+	for i, text := range texts {
+		t, err := template.New(fmt.Sprintf("%d", i)).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		// Unknown fields are only caught when the template is executed.
+		if err := t.Execute(ioutil.Discard, logFields{}); err != nil {
+			return nil, err
+		}
+		templates[i] = t
+	}
+	return templates, nil
+}
+
+// logRun is a run of a container, whose logs are generated from its ID and start time.
+type logRun struct {
+	id    string
+	start time.Time
+	// end is when the run ended, or zero while the container is running.
+	end time.Time
+}
+
+// containerRun returns the run of the container whose logs are read: the current one, or
+// the one before if previous is set. A container waiting to be restarted has its last run
+// as the current one, like with the kubelet.
+func containerRun(pod *v1.Pod, cs *v1.ContainerStatus, previous bool) (logRun, error) {
+	state, last := cs.State, cs.LastTerminationState.Terminated
+	if state.Waiting != nil && last != nil {
+		state, last = cs.LastTerminationState, nil
+	}
+	if previous {
+		if last == nil {
+			return logRun{}, errdefs.InvalidInputf("previous terminated container %q in pod %q not found", cs.Name, pod.Name)
+		}
+		return logRun{id: last.ContainerID, start: last.StartedAt.Time, end: last.FinishedAt.Time}, nil
+	}
+	switch {
+	case state.Running != nil:
+		return logRun{id: cs.ContainerID, start: state.Running.StartedAt.Time}, nil
+	case state.Terminated != nil:
+		return logRun{id: state.Terminated.ContainerID, start: state.Terminated.StartedAt.Time, end: state.Terminated.FinishedAt.Time}, nil
+	}
+	reason := ""
+	if state.Waiting != nil {
+		reason = state.Waiting.Reason
+	}
+	return logRun{}, errdefs.InvalidInputf("container %q in pod %q is waiting to start: %s", cs.Name, pod.Name, reason)
+}
+
+// findPodContainerStatus returns the status of the named init or app container of the
+// pod, or nil if there is none.
+func findPodContainerStatus(pod *v1.Pod, name string) *v1.ContainerStatus {
+	if cs := findContainerStatus(pod.Status.InitContainerStatuses, name); cs != nil {
+		return cs
+	}
+	return findContainerStatus(pod.Status.ContainerStatuses, name)
+}
+
+// findPodContainer returns the named init or app container of the pod, or nil if there is
+// none.
+func findPodContainer(pod *v1.Pod, name string) *v1.Container {
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// logGenerator generates the lines of a container run. Line i is written i intervals into
+// the run, and what it says is drawn from a hash of the run ID and i. When a fixture is
+// replayed its lines are written at their offset into the run instead.
+type logGenerator struct {
+	config   LogsConfig
+	fields   logFields
+	run      logRun
+	seed     uint64
+	interval time.Duration
+	fixture  *logFixture
+}
+
+func newLogGenerator(config LogsConfig, namespace, pod, container string, run logRun, fixture *logFixture) *logGenerator {
+	h := fnv.New64a()
+	h.Write([]byte(run.id)) //nolint:errcheck
+	g := &logGenerator{
+		config:  config,
+		fields:  logFields{Namespace: namespace, Pod: pod, Container: container},
+		run:     run,
+		seed:    h.Sum64(),
+		fixture: fixture,
+	}
+	if config.Rate > 0 {
+		g.interval = time.Duration(float64(time.Second) / config.Rate)
+		if g.interval <= 0 {
+			g.interval = 1
+		}
+	}
+	return g
+}
+
+// count returns the number of lines written by t.
+func (g *logGenerator) count(t time.Time) int64 {
+	if g.fixture != nil {
+		return g.fixture.count(t.Sub(g.run.start))
+	}
+	if g.interval == 0 || t.Before(g.run.start) {
+		return 0
+	}
+	return int64(t.Sub(g.run.start)/g.interval) + 1
+}
+
+// time returns when line i is written.
+func (g *logGenerator) time(i int64) time.Time {
+	if g.fixture != nil {
+		return g.run.start.Add(g.fixture.offset(i))
+	}
+	return g.run.start.Add(time.Duration(i) * g.interval)
+}
+
+// line returns line i, terminated by a newline.
+func (g *logGenerator) line(i int64, timestamps bool) (string, error) {
+	if g.fixture != nil {
+		line := g.fixture.line(i)
+		if timestamps {
+			line = g.time(i).UTC().Format(time.RFC3339Nano) + " " + line
+		}
+		return line + "\n", nil
+	}
+	h := splitMix64(g.seed + uint64(i))
+	level, templates := logLevelInfo, g.config.templates
+	if float64(h>>11)/(1<<53) < g.config.ErrorRatio {
+		level, templates = logLevelError, g.config.errorTemplates
+	}
+	fields := g.fields
+	fields.Line = i + 1
+	fields.Level = level
+	fields.Time = g.time(i)
+
+	var b strings.Builder
+	if err := templates[splitMix64(h)%uint64(len(templates))].Execute(&b, fields); err != nil {
+		return "", err
+	}
+	line := b.String()
+	if g.config.Format == logFormatJSON {
+		data, err := json.Marshal(struct {
+			Time    string `json:"time"`
+			Level   string `json:"level"`
+			Message string `json:"msg"`
+		}{fields.Time.UTC().Format(time.RFC3339Nano), strings.ToLower(level), line})
+		if err != nil {
+			return "", err
+		}
+		line = string(data)
+	}
+	if timestamps {
+		line = fields.Time.UTC().Format(time.RFC3339Nano) + " " + line
+	}
+	return line + "\n", nil
+}
+
+// splitMix64 scrambles x into a well distributed hash.
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// errLogLimit stops the logs once LimitBytes have been written.
+var errLogLimit = errors.New("log limit reached")
+
+// limitWriter writes up to n bytes, or any number of bytes if n is not positive.
+type limitWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitWriter) write(s string) error {
+	if l.n > 0 {
+		if len(s) >= l.n {
+			s = s[:l.n]
+			_, err := io.WriteString(l.w, s)
+			if err == nil {
+				err = errLogLimit
+			}
+			return err
+		}
+		l.n -= len(s)
+	}
+	_, err := io.WriteString(l.w, s)
+	return err
+}
+
+// GetContainerLogsWithOpts returns the logs the container has written, generated as
+// configured in the behavior of its pod. Following the logs streams the lines as they are
+// written, until the container stops running.
+func (p *MockProvider) GetContainerLogsWithOpts(ctx context.Context, namespace, podName, containerName string, opts provider.ContainerLogOpts) (io.ReadCloser, error) {
+	key, err := buildKeyFromNames(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	mp, exists := p.pods[key]
+	if !exists {
+		p.mu.Unlock()
+		return nil, errdefs.NotFoundf("pod \"%s/%s\" is not known to the provider", namespace, podName)
+	}
+	config := mp.behavior.Logs
+	cs := findPodContainerStatus(mp.pod, containerName)
+	container := findPodContainer(mp.pod, containerName)
+	if cs == nil || container == nil {
+		p.mu.Unlock()
+		return nil, errdefs.NotFoundf("container %q not found in pod \"%s/%s\"", containerName, namespace, podName)
+	}
+	run, err := containerRun(mp.pod, cs, opts.Previous)
+	fixture := config.fixtureFor(mp.pod, container.Image)
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	g := newLogGenerator(config, namespace, podName, containerName, run, fixture)
+	r, w := io.Pipe()
+	go func() {
+		err := p.writeLogs(ctx, &limitWriter{w: w, n: opts.LimitBytes}, g, opts)
+		if err == errLogLimit {
+			err = nil
+		}
+		w.CloseWithError(err) //nolint:errcheck
+	}()
+	return r, nil
+}
+
+// writeLogs writes the lines of the run that the options select, then follows the run if
+// asked to.
+func (p *MockProvider) writeLogs(ctx context.Context, w *limitWriter, g *logGenerator, opts provider.ContainerLogOpts) error {
+	now := time.Now()
+	end := g.run.end
+	if end.IsZero() {
+		end = now
+	}
+	since := opts.SinceTime
+	if since.IsZero() && opts.SinceSeconds > 0 {
+		since = now.Add(-time.Duration(opts.SinceSeconds) * time.Second)
+	}
+	var first int64
+	if !since.IsZero() {
+		first = g.count(since.Add(-1))
+	}
+	n := g.count(end)
+	if opts.Tail >= 0 && n-int64(opts.Tail) > first {
+		first = n - int64(opts.Tail)
+	}
+	if err := g.writeLines(w, first, n, opts.Timestamps); err != nil {
+		return err
+	}
+	if !opts.Follow || !g.run.end.IsZero() {
+		return nil
+	}
+
+	for {
+		wait := followPollInterval
+		if g.interval > 0 || g.fixture != nil {
+			if untilNext := time.Until(g.time(n)); untilNext > wait {
+				wait = untilNext
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		end, running := p.runEnd(g)
+		last := g.count(end)
+		if err := g.writeLines(w, n, last, opts.Timestamps); err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+		n = last
+	}
+}
+
+func (g *logGenerator) writeLines(w *limitWriter, from, to int64, timestamps bool) error {
+	for i := from; i < to; i++ {
+		line, err := g.line(i, timestamps)
+		if err != nil {
+			return err
+		}
+		if err := w.write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runEnd returns how far the run of the container has gone: now if it is still running,
+// otherwise when it ended.
+func (p *MockProvider) runEnd(g *logGenerator) (time.Time, bool) {
+	now := time.Now()
+	pod := p.store.get(g.fields.Namespace, g.fields.Pod)
+	if pod == nil {
+		return now, false
+	}
+	cs := findPodContainerStatus(pod, g.fields.Container)
+	if cs == nil {
+		return now, false
+	}
+	if cs.State.Running != nil && cs.ContainerID == g.run.id {
+		return now, true
+	}
+	for _, state := range []v1.ContainerState{cs.State, cs.LastTerminationState} {
+		if t := state.Terminated; t != nil && t.ContainerID == g.run.id {
+			return t.FinishedAt.Time, false
+		}
+	}
+	return now, false
+}
@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VineethReddy02/mocklet/internal/provider"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+func readLogs(t *testing.T, p *MockProvider, pod string, opts provider.ContainerLogOpts) []string {
+	t.Helper()
+	logs, err := p.GetContainerLogsWithOpts(context.Background(), "default", pod, "app", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	data, err := ioutil.ReadAll(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestContainerLogs(t *testing.T) {
+	defer func(d time.Duration) { followPollInterval = d }(followPollInterval)
+	followPollInterval = 10 * time.Millisecond
+
+	p, ch := newTestProvider(t, MockConfig{
+		Behavior: Behavior{Logs: LogsConfig{
+			Rate:           100,
+			Templates:      []string{"{{.Level}} {{.Pod}}/{{.Container}} line {{.Line}}"},
+			ErrorRatio:     0.5,
+			ErrorTemplates: []string{"{{.Level}} line {{.Line}} failed"},
+		}},
+		Profiles: []Profile{{Name: "json", Selector: "format=json", Behavior: Behavior{Logs: LogsConfig{Format: "json"}}}},
+	})
+	defer stopPods(p)
+	job := newTestPod("job", "app")
+	job.Annotations = map[string]string{annotationRunDuration: "300ms"}
+	job.Spec.RestartPolicy = v1.RestartPolicyNever
+	structured := newTestPod("structured", "app")
+	structured.Labels = map[string]string{"format": "json"}
+	for _, pod := range []*v1.Pod{newTestPod("web", "app"), job, structured} {
+		if err := p.CreatePod(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+		name := pod.Name
+		waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == name && pod.Status.Phase == v1.PodRunning })
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// The lines of a run are the same every time they are read.
+	all := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: -1})
+	tail := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: 5})
+	again := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: -1})
+	if len(all) < 10 || len(tail) != 5 {
+		t.Fatalf("expected at least 10 lines and a tail of 5, got %d and %d", len(all), len(tail))
+	}
+	if !strings.HasPrefix(strings.Join(again, "\n"), strings.Join(all, "\n")) ||
+		!strings.Contains(strings.Join(again, "\n"), strings.Join(tail, "\n")) {
+		t.Fatalf("expected the logs to be read the same every time, got %q then %q", all, again)
+	}
+	var errors int
+	for i, line := range all[:10] {
+		if line != fmt.Sprintf("INFO web/app line %d", i+1) && line != fmt.Sprintf("ERROR line %d failed", i+1) {
+			t.Fatalf("unexpected line %q", line)
+		}
+		if strings.HasPrefix(line, "ERROR") {
+			errors++
+		}
+	}
+	if errors == 0 || errors == 10 {
+		t.Fatalf("expected some of the lines to be errors, got %d out of 10", errors)
+	}
+
+	if logs := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: -1, LimitBytes: 7}); len(logs) != 1 || len(logs[0]) != 7 {
+		t.Fatalf("expected the logs to be cut at 7 bytes, got %q", logs)
+	}
+	if logs := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: -1, SinceTime: time.Now().Add(time.Hour)}); len(logs) != 0 {
+		t.Fatalf("expected no lines since a time to come, got %d", len(logs))
+	}
+	logs := readLogs(t, p, "web", provider.ContainerLogOpts{Tail: 1, Timestamps: true})
+	if ts := strings.SplitN(logs[0], " ", 2)[0]; len(logs) != 1 {
+		t.Fatalf("expected one line, got %q", logs)
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Fatalf("expected the line to start with its timestamp, got %q", logs[0])
+	}
+
+	logs = readLogs(t, p, "structured", provider.ContainerLogOpts{Tail: 1})
+	var entry struct{ Time, Level, Msg string }
+	if err := json.Unmarshal([]byte(logs[0]), &entry); err != nil || entry.Time == "" || entry.Msg == "" {
+		t.Fatalf("expected a JSON line, got %q", logs[0])
+	}
+
+	// Following the logs streams the lines until the container stops.
+	follow := readLogs(t, p, "job", provider.ContainerLogOpts{Tail: -1, Follow: true})
+	if finished := readLogs(t, p, "job", provider.ContainerLogOpts{Tail: -1}); len(follow) != len(finished) || len(follow) < 25 {
+		t.Fatalf("expected to follow the %d lines of the run, got %d", len(finished), len(follow))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := p.GetContainerLogsWithOpts(ctx, "default", "web", "app", provider.ContainerLogOpts{Tail: 0, Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(stream)
+	for i := 0; i < 3; i++ {
+		if !scanner.Scan() {
+			t.Fatalf("expected followed lines, got %v", scanner.Err())
+		}
+	}
+	cancel()
+	if _, err := ioutil.ReadAll(stream); err != context.Canceled {
+		t.Fatalf("expected the stream to end when the request is cancelled, got %v", err)
+	}
+	stream.Close()
+
+	if _, err := p.GetContainerLogsWithOpts(context.Background(), "default", "job", "app", provider.ContainerLogOpts{Previous: true}); !errdefs.IsInvalidInput(err) {
+		t.Fatalf("expected no previous run, got %v", err)
+	}
+	if _, err := p.GetContainerLogsWithOpts(context.Background(), "default", "web", "db", provider.ContainerLogOpts{}); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected the container not to be found, got %v", err)
+	}
+}
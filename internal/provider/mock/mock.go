@@ -9,8 +9,11 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/VineethReddy02/mocklet/internal/provider"
+	"github.com/VineethReddy02/mocklet/manager"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
@@ -18,6 +21,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
 
@@ -26,6 +30,8 @@ const (
 	defaultCPUCapacity    = "20"
 	defaultMemoryCapacity = "100Gi"
 	defaultPodCapacity    = "20"
+	defaultNodeFsCapacity = "100Gi"
+	defaultMaxPIDs        = 32768
 
 	// Values used in tracing as attribute keys.
 	namespaceKey     = "namespace"
@@ -48,10 +54,43 @@ type MockProvider struct { // nolint:golint
 	operatingSystem    string
 	internalIP         string
 	daemonEndpointPort int32
-	pods               map[string]*v1.Pod
 	config             MockConfig
 	startTime          time.Time
 	notifier           func(*v1.Pod)
+	// resourceManager lists the pods bound to the node, which are adopted at startup.
+	resourceManager *manager.ResourceManager
+	// clusterDomain is the DNS domain of the cluster, written to the hosts file of pods.
+	clusterDomain string
+	// recorder records the events of pods, such as volumes failing to mount.
+	recorder record.EventRecorder
+
+	// mu guards pods, restored, images, ipPool, node, conditions, capacity, allocatable, cpu and
+	// pingFailure. Lifecycle transitions run on timers, concurrently with the pod controller.
+	mu   sync.Mutex
+	pods map[string]*mockPod
+	// restored are the pods restored from the state file, whose lifecycle resumes once
+	// AdoptPods is called.
+	restored []*mockPod
+	// store holds the snapshots of the pods served by GetPod and GetPods, which do not
+	// take mu. They are published whenever the pods are notified.
+	store *podStore
+	// images are the images pulled onto the node.
+	images map[string]struct{}
+	// ipPool allocates the pod IPs.
+	ipPool *ipPool
+	// node is the node as configured, which pods are admitted against.
+	node *v1.Node
+	// conditions are the node conditions, which can change at runtime.
+	conditions []v1.NodeCondition
+	// capacity and allocatable are the resources of the node, which can change at runtime.
+	capacity    v1.ResourceList
+	allocatable v1.ResourceList
+	// cpu adds up the CPU time used on the node, as reported in the stats summary.
+	cpu cpuCounter
+	// pingFailure, when set, is the error Ping fails with.
+	pingFailure string
+	// nodeChanged signals that the node status changed and must be pushed.
+	nodeChanged chan struct{}
 }
 
 // MockConfig contains a mock mocklet's configurable parameters.
@@ -59,6 +98,52 @@ type MockConfig struct { //nolint:golint
 	CPU    string `yaml:"cpu,omitempty"`
 	Memory string `yaml:"memory,omitempty"`
 	Pods   string `yaml:"pods,omitempty"`
+	// EphemeralStorage is the capacity of the node filesystem. Defaults to 100Gi.
+	EphemeralStorage string `yaml:"ephemeralStorage,omitempty"`
+	// MaxPIDs is the number of processes the node can run. Defaults to 32768.
+	MaxPIDs int64 `yaml:"maxPIDs,omitempty"`
+
+	Network  NetworkConfig  `yaml:"network,omitempty"`
+	Eviction EvictionConfig `yaml:"eviction,omitempty"`
+	// Conditions replace the node conditions of the same type, or add new ones.
+	Conditions []NodeConditionConfig `yaml:"conditions,omitempty"`
+	State      StateConfig           `yaml:"state,omitempty"`
+	Behavior   `yaml:",inline"`
+	// Profiles override the node behavior for the pods they select. The first matching
+	// profile applies.
+	Profiles []Profile `yaml:"profiles,omitempty"`
+
+	// selectsNamespaces is set if a profile selects namespaces by their labels.
+	selectsNamespaces bool
+}
+
+// StartupConfig controls how long pods take to come up once they are bound to the node.
+// Unset distributions make the corresponding transition immediate.
+type StartupConfig struct {
+	// Started is the time from the pod being scheduled to its pod sandbox being created.
+	// Until then the pod is Pending and its containers are ContainerCreating.
+	Started Distribution `yaml:"started,omitempty"`
+	// InitContainers is how long each init container runs before completing.
+	InitContainers Distribution `yaml:"initContainers,omitempty"`
+	// Containers is the time each app container takes to start, counted from the
+	// start of the previous container.
+	Containers Distribution `yaml:"containers,omitempty"`
+	// Ready is the time from a container running to it becoming ready.
+	Ready Distribution `yaml:"ready,omitempty"`
+}
+
+func (c StartupConfig) validate() error {
+	for name, d := range map[string]Distribution{
+		"started":        c.Started,
+		"initContainers": c.InitContainers,
+		"containers":     c.Containers,
+		"ready":          c.Ready,
+	} {
+		if err := d.validate(); err != nil {
+			return fmt.Errorf("invalid startup.%s: %v", name, err)
+		}
+	}
+	return nil
 }
 
 // NewMockProviderMockConfig creates a new MockV0Provider. Mock legacy provider does not implement the new asynchronous podnotifier interface
@@ -73,32 +158,84 @@ func NewMockProviderMockConfig(config MockConfig, nodeName, operatingSystem stri
 	if config.Pods == "" {
 		config.Pods = defaultPodCapacity
 	}
+	if config.EphemeralStorage == "" {
+		config.EphemeralStorage = defaultNodeFsCapacity
+	}
+	if config.MaxPIDs == 0 {
+		config.MaxPIDs = defaultMaxPIDs
+	}
+	capacity, err := configuredCapacity(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Behavior.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.compileProfiles(); err != nil {
+		return nil, err
+	}
+	if err := config.Network.validate(internalIP); err != nil {
+		return nil, err
+	}
+	if err := config.Eviction.compile(); err != nil {
+		return nil, err
+	}
+	if err := validateNodeConditions(config.Conditions); err != nil {
+		return nil, err
+	}
+	if err := config.State.validate(); err != nil {
+		return nil, err
+	}
+	podCIDR := config.Network.PodCIDR
+	if podCIDR == "" {
+		podCIDR = defaultPodCIDR
+	}
+	ipPool, err := newIPPool(podCIDR)
+	if err != nil {
+		return nil, err
+	}
 	provider := MockProvider{
 		nodeName:           nodeName,
 		operatingSystem:    operatingSystem,
 		internalIP:         internalIP,
 		daemonEndpointPort: daemonEndpointPort,
-		pods:               make(map[string]*v1.Pod),
+		pods:               make(map[string]*mockPod),
+		store:              newPodStore(),
+		images:             make(map[string]struct{}),
+		ipPool:             ipPool,
 		config:             config,
 		startTime:          time.Now(),
+		capacity:           capacity,
+		allocatable:        capacity.DeepCopy(),
+		nodeChanged:        make(chan struct{}, 1),
 	}
+	provider.conditions = initialNodeConditions(config.Conditions, metav1.NewTime(provider.startTime))
 
 	return &provider, nil
 }
 
 // NewMockProvider creates a new MockProvider, which implements the PodNotifier interface
-func NewMockProvider(providerConfig, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32) (*MockProvider, error) {
+func NewMockProvider(providerConfig, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string, resourceManager *manager.ResourceManager, recorder record.EventRecorder) (*MockProvider, error) {
 	config, err := loadConfig(providerConfig, nodeName)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewMockProviderMockConfig(config, nodeName, operatingSystem, internalIP, daemonEndpointPort)
+	p, err := NewMockProviderMockConfig(config, nodeName, operatingSystem, internalIP, daemonEndpointPort)
+	if err != nil {
+		return nil, err
+	}
+	p.resourceManager = resourceManager
+	p.clusterDomain = clusterDomain
+	p.recorder = recorder
+	if err := p.loadState(); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 // loadConfig loads the given json configuration files.
 func loadConfig(providerConfig, nodeName string) (config MockConfig, err error) {
-	fmt.Println(providerConfig)
 	if providerConfig != "" {
 		data, err := ioutil.ReadFile(providerConfig)
 		if err != nil {
@@ -127,8 +264,7 @@ func loadConfig(providerConfig, nodeName string) (config MockConfig, err error)
 		config.Memory = os.Getenv("NODE_MEMORY")
 	}
 
-
-	fmt.Printf("Using config as number of pods= %s, node cpu = %s, node memory = %s", config.Pods, config.CPU, config.Memory)
+	log.G(context.TODO()).Debugf("Using config as number of pods= %s, node cpu = %s, node memory = %s", config.Pods, config.CPU, config.Memory)
 
 	if _, err = resource.ParseQuantity(config.CPU); err != nil {
 		return config, fmt.Errorf("Invalid CPU value %v", config.CPU)
@@ -170,50 +306,48 @@ func (p *MockProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	if err != nil {
 		return err
 	}
+	// Pods with invalid annotations fail like pods the kubelet rejects, since the pod
+	// controller only logs the errors returned.
+	behavior, behaviorErr := p.behaviorFor(pod)
 	now := metav1.NewTime(time.Now())
-	pod.Status = v1.PodStatus{
-		Phase:     v1.PodRunning,
-		HostIP:    "1.2.3.4",
-		PodIP:     "5.6.7.8",
-		StartTime: &now,
-		Conditions: []v1.PodCondition{
-			{
-				Type:   v1.PodInitialized,
-				Status: v1.ConditionTrue,
-			},
-			{
-				Type:   v1.PodReady,
-				Status: v1.ConditionTrue,
-			},
-			{
-				Type:   v1.PodScheduled,
-				Status: v1.ConditionTrue,
-			},
-		},
-	}
 
-	for _, container := range pod.Spec.Containers {
-		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{
-			Name:         container.Name,
-			Image:        container.Image,
-			Ready:        true,
-			RestartCount: 0,
-			State: v1.ContainerState{
-				Running: &v1.ContainerStateRunning{
-					StartedAt: now,
-				},
-			},
-			ContainerID: RandStringRunes(64),
-		})
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if old, exists := p.pods[key]; exists {
+		old.stop()
+		p.releasePodIP(old)
+	}
+	mp := newMockPod(pod, behavior)
+	if behaviorErr != nil {
+		p.pods[key] = mp
+		rejectPod(pod, reasonInvalidAnnotations, behaviorErr.Error())
+		p.eventf(pod, v1.EventTypeWarning, reasonInvalidAnnotations, "%v", behaviorErr)
+		p.notifyPod(mp)
+		return nil
+	}
+	if reason, message := p.admitPod(key, pod); reason != "" {
+		p.pods[key] = mp
+		rejectPod(pod, reason, message)
+		p.notifyPod(mp)
+		return nil
+	}
+	if p.config.Network.OnExhaustion == exhaustionReject {
+		if mp.podIP, err = p.ipPool.allocate(); err != nil {
+			delete(p.pods, key)
+			p.store.delete(pod.Namespace, pod.Name)
+			return fmt.Errorf("failed to allocate an IP for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
 	}
-
-	p.pods[key] = pod
-	p.notifier(pod)
+	p.pods[key] = mp
+	p.startPod(mp, now)
+	p.notifyPod(mp)
 
 	return nil
 }
 
 // UpdatePod accepts a Pod definition and updates its reference.
+// The simulated status of the pod is kept as is, apart from a change of forced readiness.
 func (p *MockProvider) UpdatePod(ctx context.Context, pod *v1.Pod) error {
 	ctx, span := trace.StartSpan(ctx, "UpdatePod")
 	defer span.End()
@@ -227,14 +361,37 @@ func (p *MockProvider) UpdatePod(ctx context.Context, pod *v1.Pod) error {
 	if err != nil {
 		return err
 	}
+	behavior, behaviorErr := p.behaviorFor(pod)
 
-	p.pods[key] = pod
-	p.notifier(pod)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mp, exists := p.pods[key]
+	if !exists {
+		return errdefs.NotFoundf("pod \"%s/%s\" is not known to the provider", pod.Namespace, pod.Name)
+	}
+	// Invalid annotations on a pod already started are reported and ignored: the pod keeps
+	// its behavior.
+	if behaviorErr != nil {
+		log.G(ctx).Warn(behaviorErr)
+		p.eventf(pod, v1.EventTypeWarning, reasonInvalidAnnotations, "%v", behaviorErr)
+		behavior = mp.behavior
+	}
+	mp.pod.ObjectMeta = pod.ObjectMeta
+	mp.pod.Spec = pod.Spec
+	// The new behavior drives the transitions scheduled from now on.
+	readinessChanged := !equalBoolPtr(mp.behavior.Ready, behavior.Ready)
+	mp.behavior = behavior
+	if readinessChanged {
+		forceReadiness(mp, metav1.Now())
+	}
+	p.notifyPod(mp)
 
 	return nil
 }
 
-// DeletePod deletes the specified pod out of memory.
+// DeletePod terminates the specified pod, honoring its grace period, and deletes it out
+// of memory once its containers have stopped.
 func (p *MockProvider) DeletePod(ctx context.Context, pod *v1.Pod) (err error) {
 	ctx, span := trace.StartSpan(ctx, "DeletePod")
 	defer span.End()
@@ -249,28 +406,26 @@ func (p *MockProvider) DeletePod(ctx context.Context, pod *v1.Pod) (err error) {
 		return err
 	}
 
-	if _, exists := p.pods[key]; !exists {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	mp, exists := p.pods[key]
+	if !exists {
 		return errdefs.NotFound("pod not found")
 	}
 
-	now := metav1.Now()
-	delete(p.pods, key)
-	pod.Status.Phase = v1.PodSucceeded
-	pod.Status.Reason = "MockProviderPodDeleted"
-
-	for idx := range pod.Status.ContainerStatuses {
-		pod.Status.ContainerStatuses[idx].Ready = false
-		pod.Status.ContainerStatuses[idx].State = v1.ContainerState{
-			Terminated: &v1.ContainerStateTerminated{
-				Message:    "Mock provider terminated container upon deletion",
-				FinishedAt: now,
-				Reason:     "MockProviderPodContainerDeleted",
-				StartedAt:  pod.Status.ContainerStatuses[idx].State.Running.StartedAt,
-			},
-		}
+	// A pod stuck terminating keeps running. The deletion is retried, so removing the
+	// annotation lets the pod terminate.
+	if behavior, err := p.behaviorFor(pod); err == nil && behavior.StuckTerminating {
+		mp.pod.ObjectMeta = pod.ObjectMeta
+		mp.behavior = behavior
+		p.publishPod(mp)
+		return fmt.Errorf("pod \"%s/%s\" is stuck terminating", pod.Namespace, pod.Name)
 	}
 
-	p.notifier(pod)
+	mp.pod.ObjectMeta = pod.ObjectMeta
+	p.terminatePod(mp, key, gracePeriod(pod), metav1.Now())
+	p.notifyPod(mp)
 
 	return nil
 }
@@ -288,12 +443,7 @@ func (p *MockProvider) GetPod(ctx context.Context, namespace, name string) (pod
 
 	log.G(ctx).Infof("receive GetPod %q", name)
 
-	key, err := buildKeyFromNames(namespace, name)
-	if err != nil {
-		return nil, err
-	}
-
-	if pod, ok := p.pods[key]; ok {
+	if pod := p.store.get(namespace, name); pod != nil {
 		return pod, nil
 	}
 	return nil, errdefs.NotFoundf("pod \"%s/%s\" is not known to the provider", namespace, name)
@@ -308,14 +458,20 @@ func (p *MockProvider) GetContainerLogs(ctx context.Context, namespace, podName,
 	ctx = addAttributes(ctx, span, namespaceKey, namespace, nameKey, podName, containerNameKey, containerName)
 
 	log.G(ctx).Infof("receive GetContainerLogs %q", podName)
-	return ioutil.NopCloser(strings.NewReader("")), nil
-}
 
-// RunInContainer executes a command in a container in the pod, copying data
-// between in/out/err and the container's stdin/stdout/stderr.
-func (p *MockProvider) RunInContainer(ctx context.Context, namespace, name, container string, cmd []string, attach api.AttachIO) error {
-	log.G(context.TODO()).Infof("receive ExecInContainer %q", container)
-	return nil
+	// A zero tail means no tail, and Since counts back from now.
+	logOpts := provider.ContainerLogOpts{
+		Tail:       opts.Tail,
+		LimitBytes: opts.LimitBytes,
+		Timestamps: opts.Timestamps,
+	}
+	if logOpts.Tail == 0 {
+		logOpts.Tail = -1
+	}
+	if opts.Since > 0 {
+		logOpts.SinceTime = time.Now().Add(-opts.Since)
+	}
+	return p.GetContainerLogsWithOpts(ctx, namespace, podName, containerName, logOpts)
 }
 
 // GetPodStatus returns the status of a pod by name that is "running".
@@ -344,22 +500,20 @@ func (p *MockProvider) GetPods(ctx context.Context) ([]*v1.Pod, error) {
 
 	log.G(ctx).Info("receive GetPods")
 
-	var pods []*v1.Pod
-
-	for _, pod := range p.pods {
-		pods = append(pods, pod)
-	}
-
-	return pods, nil
+	return p.store.list(), nil
 }
 
 func (p *MockProvider) ConfigureNode(ctx context.Context, n *v1.Node) {
 	ctx, span := trace.StartSpan(ctx, "mock.ConfigureNode") //nolint:ineffassign
 	defer span.End()
 
-	n.Status.Capacity = p.capacity()
-	n.Status.Allocatable = p.capacity()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n.Status.Capacity = p.capacity.DeepCopy()
+	n.Status.Allocatable = p.allocatable.DeepCopy()
 	n.Status.Conditions = p.nodeConditions()
+	n.Status.Images = p.nodeImages()
 	n.Status.Addresses = p.nodeAddresses()
 	n.Status.DaemonEndpoints = p.nodeDaemonEndpoints()
 	os := p.operatingSystem
@@ -369,79 +523,37 @@ func (p *MockProvider) ConfigureNode(ctx context.Context, n *v1.Node) {
 	n.Status.NodeInfo.OperatingSystem = os
 	n.Status.NodeInfo.Architecture = "amd64"
 	n.ObjectMeta.Labels["alpha.service-controller.kubernetes.io/exclude-balancer"] = "true"
-}
-
-// Capacity returns a resource list containing the capacity limits.
-func (p *MockProvider) capacity() v1.ResourceList {
-	return v1.ResourceList{
-		"cpu":    resource.MustParse(p.config.CPU),
-		"memory": resource.MustParse(p.config.Memory),
-		"pods":   resource.MustParse(p.config.Pods),
+	for _, label := range []string{"kubernetes.io/os", "beta.kubernetes.io/os"} {
+		if _, ok := n.ObjectMeta.Labels[label]; !ok {
+			n.ObjectMeta.Labels[label] = strings.ToLower(os)
+		}
 	}
-}
-
-// NodeConditions returns a list of conditions (Ready, OutOfDisk, etc), for updates to the node status
-// within Kubernetes.
-func (p *MockProvider) nodeConditions() []v1.NodeCondition {
-	// TODO: Make this configurable
-	return []v1.NodeCondition{
-		{
-			Type:               "Ready",
-			Status:             v1.ConditionTrue,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletReady",
-			Message:            "kubelet is ready.",
-		},
-		{
-			Type:               "OutOfDisk",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasSufficientDisk",
-			Message:            "kubelet has sufficient disk space available",
-		},
-		{
-			Type:               "MemoryPressure",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasSufficientMemory",
-			Message:            "kubelet has sufficient memory available",
-		},
-		{
-			Type:               "DiskPressure",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletHasNoDiskPressure",
-			Message:            "kubelet has no disk pressure",
-		},
-		{
-			Type:               "NetworkUnavailable",
-			Status:             v1.ConditionFalse,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "RouteCreated",
-			Message:            "RouteController created a route",
-		},
+	for _, label := range []string{"kubernetes.io/arch", "beta.kubernetes.io/arch"} {
+		if _, ok := n.ObjectMeta.Labels[label]; !ok {
+			n.ObjectMeta.Labels[label] = n.Status.NodeInfo.Architecture
+		}
 	}
 
+	p.configurePodCIDR(ctx, n)
+	p.node = n.DeepCopy()
 }
 
 // NodeAddresses returns a list of addresses for the node status
 // within Kubernetes.
 func (p *MockProvider) nodeAddresses() []v1.NodeAddress {
-	return []v1.NodeAddress{
+	addresses := []v1.NodeAddress{
 		{
 			Type:    "InternalIP",
 			Address:  p.internalIP,
 		},
-		{
-			Type:    "Hostname",
-			Address:  p.nodeName,
-		},
 	}
+	if ip := p.config.Network.SecondaryInternalIP; ip != "" {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
+	}
+	return append(addresses, v1.NodeAddress{
+		Type:    "Hostname",
+		Address: p.nodeName,
+	})
 }
 
 // NodeDaemonEndpoints returns NodeDaemonEndpoints for the node status
@@ -454,6 +566,74 @@ func (p *MockProvider) nodeDaemonEndpoints() v1.NodeDaemonEndpoints {
 	}
 }
 
+// statsSample is what the stats summary is built from. It is taken under p.mu, and the
+// summary is built from it once the lock is released.
+type statsSample struct {
+	cpuNanoCores       uint64
+	cpuCoreNanoSeconds uint64
+	observations       map[signal]observation
+	pods               []podSample
+}
+
+type podSample struct {
+	ref       stats.PodReference
+	startTime metav1.Time
+	// total is the sum of the usage of all containers in the pod, whose CPU time includes
+	// the container runs that ended.
+	total      containerUsage
+	containers []containerSample
+}
+
+type containerSample struct {
+	name      string
+	startTime metav1.Time
+	usage     containerUsage
+}
+
+// sampleStats samples the usage of the containers of every pod, which active pods add up
+// to the node usage.
+func (p *MockProvider) sampleStats(now time.Time) statsSample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sample := statsSample{pods: make([]podSample, 0, len(p.pods))}
+	active := make(map[*mockPod][]containerUsage)
+	for _, mp := range p.pods {
+		pod := mp.pod
+		usage := mp.usage(now, p.allocatable)
+		if isActive(pod) {
+			active[mp] = usage
+			sample.cpuNanoCores += podUsage(usage).cpuNanoCores
+		}
+		ps := podSample{
+			ref: stats.PodReference{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+				UID:       string(pod.UID),
+			},
+			startTime:  pod.CreationTimestamp,
+			total:      podUsage(usage),
+			containers: make([]containerSample, len(pod.Spec.Containers)),
+		}
+		ps.total.cpuCoreNanoSeconds += mp.cpuCoreNanoSeconds
+		for i, container := range pod.Spec.Containers {
+			// Running containers report when their current run started, so restarts show up.
+			startTime := pod.CreationTimestamp
+			if i < len(pod.Status.ContainerStatuses) && pod.Status.ContainerStatuses[i].State.Running != nil {
+				startTime = pod.Status.ContainerStatuses[i].State.Running.StartedAt
+			}
+			ps.containers[i] = containerSample{name: container.Name, startTime: startTime, usage: usage[i]}
+		}
+		sample.pods = append(sample.pods, ps)
+	}
+	if p.cpu.sampled.IsZero() {
+		p.cpu.sampled = p.startTime
+	}
+	sample.cpuCoreNanoSeconds = p.cpu.add(sample.cpuNanoCores, now)
+	sample.observations = p.observeSignals(active)
+	return sample
+}
+
 // GetStatsSummary returns dummy stats for all pods known by this provider.
 func (p *MockProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
 	var span trace.Span
@@ -463,6 +643,13 @@ func (p *MockProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, err
 	// Grab the current timestamp so we can report it as the time the stats were generated.
 	time := metav1.NewTime(time.Now())
 
+	// Only sampling holds the provider lock; the summary is built from the sample.
+	sample := p.sampleStats(time.Time)
+	memory := sample.observations[signalMemoryAvailable]
+	nodeFs := sample.observations[signalNodeFsAvailable]
+	pids := sample.observations[signalPIDAvailable]
+	processes := pids.capacity - pids.available
+
 	// Create the Summary object that will later be populated with node and pod stats.
 	res := &stats.Summary{}
 
@@ -470,60 +657,78 @@ func (p *MockProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, err
 	res.Node = stats.NodeStats{
 		NodeName:  p.nodeName,
 		StartTime: metav1.NewTime(p.startTime),
+		CPU: &stats.CPUStats{
+			Time:                 time,
+			UsageNanoCores:       &sample.cpuNanoCores,
+			UsageCoreNanoSeconds: &sample.cpuCoreNanoSeconds,
+		},
+		Memory: &stats.MemoryStats{
+			Time:            time,
+			AvailableBytes:  nonNegative(memory.available),
+			UsageBytes:      nonNegative(memory.capacity - memory.available),
+			WorkingSetBytes: nonNegative(memory.capacity - memory.available),
+		},
+		Fs: &stats.FsStats{
+			Time:           time,
+			AvailableBytes: nonNegative(nodeFs.available),
+			CapacityBytes:  nonNegative(nodeFs.capacity),
+			UsedBytes:      nonNegative(nodeFs.capacity - nodeFs.available),
+		},
+		Rlimit: &stats.RlimitStats{
+			Time:                  time,
+			MaxPID:                &pids.capacity,
+			NumOfRunningProcesses: &processes,
+		},
 	}
 
 	// Populate the Summary object with dummy stats for each pod known by this provider.
-	for _, pod := range p.pods {
-		var (
-			// totalUsageNanoCores will be populated with the sum of the values of UsageNanoCores computes across all containers in the pod.
-			totalUsageNanoCores uint64
-			// totalUsageBytes will be populated with the sum of the values of UsageBytes computed across all containers in the pod.
-			totalUsageBytes uint64
-		)
+	for i := range sample.pods {
+		ps := &sample.pods[i]
 
 		// Create a PodStats object to populate with pod stats.
 		pss := stats.PodStats{
-			PodRef: stats.PodReference{
-				Name:      pod.Name,
-				Namespace: pod.Namespace,
-				UID:       string(pod.UID),
-			},
-			StartTime: pod.CreationTimestamp,
+			PodRef:    ps.ref,
+			StartTime: ps.startTime,
 		}
 
-		// Iterate over all containers in the current pod to compute dummy stats.
-		for _, container := range pod.Spec.Containers {
-			// Grab a dummy value to be used as the total CPU usage.
-			// The value should fit a uint32 in order to avoid overflows later on when computing pod stats.
-			dummyUsageNanoCores := uint64(rand.Uint32())
-			totalUsageNanoCores += dummyUsageNanoCores
-			// Create a dummy value to be used as the total RAM usage.
-			// The value should fit a uint32 in order to avoid overflows later on when computing pod stats.
-			dummyUsageBytes := uint64(rand.Uint32())
-			totalUsageBytes += dummyUsageBytes
+		// Iterate over all containers in the current pod to report their stats.
+		for j := range ps.containers {
+			c := &ps.containers[j]
 			// Append a ContainerStats object containing the dummy stats to the PodStats object.
 			pss.Containers = append(pss.Containers, stats.ContainerStats{
-				Name:      container.Name,
-				StartTime: pod.CreationTimestamp,
+				Name:      c.name,
+				StartTime: c.startTime,
 				CPU: &stats.CPUStats{
-					Time:           time,
-					UsageNanoCores: &dummyUsageNanoCores,
+					Time:                 time,
+					UsageNanoCores:       &c.usage.cpuNanoCores,
+					UsageCoreNanoSeconds: &c.usage.cpuCoreNanoSeconds,
 				},
 				Memory: &stats.MemoryStats{
-					Time:       time,
-					UsageBytes: &dummyUsageBytes,
+					Time:            time,
+					UsageBytes:      &c.usage.memoryBytes,
+					WorkingSetBytes: &c.usage.memoryBytes,
+				},
+				Rootfs: &stats.FsStats{
+					Time:      time,
+					UsedBytes: &c.usage.diskBytes,
 				},
 			})
 		}
 
-		// Populate the CPU and RAM stats for the pod and append the PodsStats object to the Summary object to be returned.
+		// Populate the CPU, RAM and disk stats for the pod and append the PodsStats object to the Summary object to be returned.
 		pss.CPU = &stats.CPUStats{
-			Time:           time,
-			UsageNanoCores: &totalUsageNanoCores,
+			Time:                 time,
+			UsageNanoCores:       &ps.total.cpuNanoCores,
+			UsageCoreNanoSeconds: &ps.total.cpuCoreNanoSeconds,
 		}
 		pss.Memory = &stats.MemoryStats{
-			Time:       time,
-			UsageBytes: &totalUsageBytes,
+			Time:            time,
+			UsageBytes:      &ps.total.memoryBytes,
+			WorkingSetBytes: &ps.total.memoryBytes,
+		}
+		pss.EphemeralStorage = &stats.FsStats{
+			Time:      time,
+			UsedBytes: &ps.total.diskBytes,
 		}
 		res.Pods = append(res.Pods, pss)
 	}
@@ -532,10 +737,37 @@ func (p *MockProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, err
 	return res, nil
 }
 
+func nonNegative(v int64) *uint64 {
+	if v < 0 {
+		v = 0
+	}
+	u := uint64(v)
+	return &u
+}
+
 // NotifyPods is called to set a pod notifier callback function. This should be called before any operations are done
 // within the provider.
 func (p *MockProvider) NotifyPods(ctx context.Context, notifier func(*v1.Pod)) {
+	p.mu.Lock()
 	p.notifier = notifier
+	// Push the pods whose status changed before there was anyone to tell.
+	for _, mp := range p.pods {
+		if mp.stale {
+			mp.stale = false
+			notifier(mp.pod.DeepCopy())
+		}
+	}
+	p.mu.Unlock()
+
+	if len(p.config.Eviction.thresholds) > 0 {
+		go p.runEvictionManager(ctx)
+	}
+	if p.config.State.Path != "" {
+		go p.runStateSaver(ctx)
+	}
+	if p.resourceManager != nil {
+		go p.runHeldPodsSync(ctx, heldPodsInterval)
+	}
 }
 
 func buildKeyFromNames(namespace string, name string) (string, error) {
@@ -1,5 +1,17 @@
 package mock
 
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
 // We can guarantee the right interfaces are implemented inside of by putting casts in place. We must do the verification
 // that a given type *does not* implement a given interface in this test.
 // Cannot implement this due to:  https://github.com/virtual-kubelet/virtual-kubelet/issues/632
@@ -10,3 +22,574 @@ func TestMockLegacyInterface(t *testing.T) {
 	assert.Assert(t, !ok)
 }
 */
+
+func newTestProvider(t *testing.T, config MockConfig) (*MockProvider, <-chan *v1.Pod) {
+	p, err := NewMockProviderMockConfig(config, "mocklet", "Linux", "10.0.0.1", 10250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AdoptPods(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan *v1.Pod, 100)
+	p.NotifyPods(context.Background(), func(pod *v1.Pod) {
+		ch <- pod
+	})
+	return p, ch
+}
+
+// stopPods cancels the pending transitions of every pod, so tests that shorten the
+// package-level delays can restore them safely.
+func stopPods(p *MockProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, mp := range p.pods {
+		mp.stop()
+	}
+}
+
+func newTestPod(name string, containers ...string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			UID:       types.UID(name),
+		},
+	}
+	for _, c := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: c, Image: "busybox"})
+	}
+	return pod
+}
+
+// waitForPod returns the first notified pod that satisfies cond.
+func waitForPod(t *testing.T, ch <-chan *v1.Pod, cond func(*v1.Pod) bool) *v1.Pod {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case pod := <-ch:
+			if cond(pod) {
+				return pod
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for pod notification")
+		}
+	}
+}
+
+func isReady(pod *v1.Pod) bool {
+	c := getPodCondition(&pod.Status, v1.PodReady)
+	return c != nil && c.Status == v1.ConditionTrue
+}
+
+func TestCreatePodStartupTransitions(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Startup: StartupConfig{
+			Started: Distribution{Type: distributionFixed, Value: 50 * time.Millisecond},
+			Ready:   Distribution{Type: distributionFixed, Value: 50 * time.Millisecond},
+		},
+	}})
+
+	if err := p.CreatePod(context.Background(), newTestPod("web", "nginx", "sidecar")); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := <-ch
+	if pod.Status.Phase != v1.PodPending {
+		t.Fatalf("expected pod to start Pending, got %s", pod.Status.Phase)
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting == nil || cs.State.Waiting.Reason != reasonContainerCreating {
+			t.Fatalf("expected container %s to be ContainerCreating, got %+v", cs.Name, cs.State)
+		}
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodRunning })
+	if isReady(pod) {
+		t.Fatal("expected pod not to be ready as soon as it is running")
+	}
+
+	pod = waitForPod(t, ch, isReady)
+	for _, cs := range pod.Status.ContainerStatuses {
+		if !cs.Ready || cs.State.Running == nil || cs.ContainerID == "" {
+			t.Fatalf("expected container %s to be running and ready, got %+v", cs.Name, cs)
+		}
+	}
+	scheduled := getPodCondition(&pod.Status, v1.PodScheduled)
+	ready := getPodCondition(&pod.Status, v1.PodReady)
+	if !ready.LastTransitionTime.After(scheduled.LastTransitionTime.Time) {
+		t.Fatal("expected Ready to transition after the pod was scheduled")
+	}
+}
+
+func TestDeletePodBeforeStart(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Startup: StartupConfig{Started: Distribution{Type: distributionFixed, Value: time.Hour}},
+	}})
+
+	pod := newTestPod("web", "nginx")
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	if err := p.DeletePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	deleted := <-ch
+	if deleted.Status.Phase != v1.PodSucceeded || deleted.Status.ContainerStatuses[0].State.Terminated == nil {
+		t.Fatalf("expected a terminal status, got %+v", deleted.Status)
+	}
+	if _, err := p.GetPod(context.Background(), "default", "web"); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected pod to be gone, got %v", err)
+	}
+}
+
+func TestCreatePodRunsInitContainersInOrder(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Startup: StartupConfig{
+			InitContainers: Distribution{Type: distributionFixed, Value: 20 * time.Millisecond},
+			Containers:     Distribution{Type: distributionFixed, Value: 20 * time.Millisecond},
+		},
+	}})
+
+	pod := newTestPod("web", "nginx", "sidecar")
+	pod.Spec.InitContainers = []v1.Container{{Name: "migrate"}, {Name: "warmup"}}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	pod = <-ch
+	if c := getPodCondition(&pod.Status, v1.PodInitialized); c.Status != v1.ConditionFalse || c.Reason != reasonContainersNotInitialized {
+		t.Fatalf("expected pod not to be initialized, got %+v", c)
+	}
+	if reason := pod.Status.ContainerStatuses[0].State.Waiting.Reason; reason != reasonPodInitializing {
+		t.Fatalf("expected app containers to wait for initialization, got %s", reason)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.InitContainerStatuses[1].State.Running != nil })
+	if first := pod.Status.InitContainerStatuses[0]; first.State.Terminated == nil || first.State.Terminated.Reason != reasonCompleted {
+		t.Fatalf("expected first init container to have completed, got %+v", first.State)
+	}
+	if pod.Status.Phase != v1.PodPending {
+		t.Fatalf("expected pod to be Pending while initializing, got %s", pod.Status.Phase)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.ContainerStatuses[0].State.Running != nil })
+	if c := getPodCondition(&pod.Status, v1.PodInitialized); c.Status != v1.ConditionTrue {
+		t.Fatalf("expected pod to be initialized, got %+v", c)
+	}
+	if pod.Status.ContainerStatuses[1].State.Running != nil {
+		t.Fatal("expected containers to start one after the other")
+	}
+
+	pod = waitForPod(t, ch, isReady)
+	initialized := getPodCondition(&pod.Status, v1.PodInitialized)
+	containersReady := getPodCondition(&pod.Status, v1.ContainersReady)
+	if !containersReady.LastTransitionTime.After(initialized.LastTransitionTime.Time) {
+		t.Fatal("expected ContainersReady to transition after Initialized")
+	}
+}
+
+func TestCrashingContainerBacksOff(t *testing.T) {
+	defer func(initial, max time.Duration) {
+		backOffInitial, backOffMax = initial, max
+	}(backOffInitial, backOffMax)
+	backOffInitial, backOffMax = 50*time.Millisecond, 100*time.Millisecond
+
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Crash: CrashConfig{After: Distribution{Type: distributionFixed, Value: 20 * time.Millisecond}, ExitCode: 2},
+	}})
+	defer stopPods(p)
+	if err := p.CreatePod(context.Background(), newTestPod("web", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := waitForPod(t, ch, func(pod *v1.Pod) bool {
+		return pod.Status.ContainerStatuses[0].RestartCount == 1
+	})
+	last := pod.Status.ContainerStatuses[0].LastTerminationState.Terminated
+	if last == nil || last.ExitCode != 2 || last.Reason != reasonError {
+		t.Fatalf("expected last termination state to record the crash, got %+v", last)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool {
+		w := pod.Status.ContainerStatuses[0].State.Waiting
+		return w != nil && w.Reason == reasonCrashLoopBackOff
+	})
+	if pod.Status.Phase != v1.PodRunning || isReady(pod) {
+		t.Fatalf("expected a crash looping pod to be Running and not ready, got %s", pod.Status.Phase)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool {
+		return pod.Status.ContainerStatuses[0].RestartCount == 2
+	})
+}
+
+func TestCrashingContainerRestartPolicyNever(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Crash: CrashConfig{Probability: 1},
+	}})
+	pod := newTestPod("job", "worker")
+	pod.Spec.RestartPolicy = v1.RestartPolicyNever
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodFailed })
+	cs := pod.Status.ContainerStatuses[0]
+	if cs.RestartCount != 0 || cs.State.Terminated == nil || cs.State.Terminated.ExitCode != 1 {
+		t.Fatalf("expected container to stay terminated, got %+v", cs)
+	}
+}
+
+func TestJobPodRunsToCompletion(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Job: JobConfig{Duration: Distribution{Type: distributionFixed, Value: 20 * time.Millisecond}},
+	}})
+
+	pod := newTestPod("job-abcde", "worker", "sidecar")
+	pod.Spec.RestartPolicy = v1.RestartPolicyOnFailure
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "batch/v1", Kind: "Job", Name: "job"}}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodSucceeded })
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Terminated == nil || cs.State.Terminated.Reason != reasonCompleted {
+			t.Fatalf("expected container %s to have completed, got %+v", cs.Name, cs.State)
+		}
+	}
+	if c := getPodCondition(&pod.Status, v1.PodReady); c.Status != v1.ConditionFalse || c.Reason != reasonPodCompleted {
+		t.Fatalf("expected a completed pod not to be ready, got %+v", c)
+	}
+}
+
+func TestRunDurationAnnotation(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+
+	pod := newTestPod("batch", "worker")
+	pod.Spec.RestartPolicy = v1.RestartPolicyNever
+	pod.Annotations = map[string]string{annotationRunDuration: "20ms", annotationExitCode: "3"}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodFailed })
+	if code := pod.Status.ContainerStatuses[0].State.Terminated.ExitCode; code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+
+	invalid := newTestPod("invalid", "worker")
+	invalid.Annotations = map[string]string{annotationRunDuration: "soon"}
+	if err := p.CreatePod(context.Background(), invalid); err != nil {
+		t.Fatal(err)
+	}
+	failed := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "invalid" && pod.Status.Phase == v1.PodFailed })
+	if failed.Status.Reason != reasonInvalidAnnotations || !strings.Contains(failed.Status.Message, annotationRunDuration) {
+		t.Fatalf("expected the pod to fail naming the annotation, got %q: %q", failed.Status.Reason, failed.Status.Message)
+	}
+
+	// Crashes cannot exit with 0, which would silently become 1.
+	zero := newTestPod("zero", "worker")
+	zero.Annotations = map[string]string{annotationFailAfter: "20ms", annotationExitCode: "0"}
+	if err := p.CreatePod(context.Background(), zero); err != nil {
+		t.Fatal(err)
+	}
+	failed = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "zero" && pod.Status.Phase == v1.PodFailed })
+	if failed.Status.Reason != reasonInvalidAnnotations || !strings.Contains(failed.Status.Message, annotationExitCode) {
+		t.Fatalf("expected the pod to fail naming the annotation, got %q: %q", failed.Status.Reason, failed.Status.Message)
+	}
+}
+
+func TestImagePullFailures(t *testing.T) {
+	defer func(initial, sync time.Duration) {
+		backOffInitial, podSyncDelay = initial, sync
+	}(backOffInitial, podSyncDelay)
+	backOffInitial, podSyncDelay = 100*time.Millisecond, 10*time.Millisecond
+
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		ImagePull: ImagePullConfig{Rules: []ImagePullRule{
+			{Image: "*:broken"},
+			{Regex: `^registry\.example\.com/`, WithoutPullSecrets: true, Message: "pull access denied"},
+		}},
+	}})
+	defer stopPods(p)
+
+	pod := newTestPod("web", "app", "private", "authenticated")
+	pod.Spec.Containers[0].Image = "example.com/team/app:broken"
+	pod.Spec.Containers[1].Image = "registry.example.com/private:1.0"
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.ContainerStatuses[2].State.Running != nil })
+	for i, message := range []string{"manifest for example.com/team/app:broken not found", "pull access denied"} {
+		w := pod.Status.ContainerStatuses[i].State.Waiting
+		if w == nil || w.Reason != reasonErrImagePull || !strings.Contains(w.Message, message) {
+			t.Fatalf("expected container %d to fail pulling with %q, got %+v", i, message, pod.Status.ContainerStatuses[i].State)
+		}
+	}
+	if pod.Status.Phase != v1.PodPending {
+		t.Fatalf("expected pod to stay Pending, got %s", pod.Status.Phase)
+	}
+
+	waitForPod(t, ch, func(pod *v1.Pod) bool {
+		w := pod.Status.ContainerStatuses[0].State.Waiting
+		return w != nil && w.Reason == reasonImagePullBackOff
+	})
+	// The pull is retried after the back-off and fails again.
+	waitForPod(t, ch, func(pod *v1.Pod) bool {
+		w := pod.Status.ContainerStatuses[0].State.Waiting
+		return w != nil && w.Reason == reasonErrImagePull
+	})
+
+	pod = newTestPod("with-secret", "private")
+	pod.Spec.Containers[0].Image = "registry.example.com/private:1.0"
+	pod.Spec.ImagePullSecrets = []v1.LocalObjectReference{{Name: "registry"}}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "with-secret" && pod.Status.Phase == v1.PodRunning })
+
+	// Images that are never pulled must already be on the node, until another pod pulls them.
+	never := newTestPod("never", "cache")
+	never.Spec.Containers[0].Image = "cache:1.0"
+	never.Spec.Containers[0].ImagePullPolicy = v1.PullNever
+	if err := p.CreatePod(context.Background(), never); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool {
+		w := pod.Status.ContainerStatuses[0].State.Waiting
+		return pod.Name == "never" && w != nil && w.Reason == reasonErrImageNeverPull
+	})
+	puller := newTestPod("puller", "cache")
+	puller.Spec.Containers[0].Image = "cache:1.0"
+	if err := p.CreatePod(context.Background(), puller); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "never" && pod.Status.Phase == v1.PodRunning })
+}
+
+func TestReadinessProbeGatesReady(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Probes: ProbeConfig{Readiness: ProbeOutcome{Schedule: []ProbeWindow{
+			{After: 0, SuccessRate: 0},
+			{After: 1500 * time.Millisecond, SuccessRate: 1},
+		}}},
+	}})
+	defer stopPods(p)
+
+	pod := newTestPod("web", "nginx")
+	pod.Spec.Containers[0].ReadinessProbe = &v1.Probe{PeriodSeconds: 1}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodRunning })
+	if isReady(pod) {
+		t.Fatal("expected pod to stay unready until its readiness probe succeeds")
+	}
+	pod = waitForPod(t, ch, isReady)
+	started := pod.Status.ContainerStatuses[0].State.Running.StartedAt
+	ready := getPodCondition(&pod.Status, v1.PodReady).LastTransitionTime
+	if ready.Sub(started.Time) < 1500*time.Millisecond {
+		t.Fatalf("expected pod to become ready after its probe schedule, got %s", ready.Sub(started.Time))
+	}
+}
+
+func TestLivenessProbeRestartsContainer(t *testing.T) {
+	never := 0.0
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Probes: ProbeConfig{Liveness: ProbeOutcome{SuccessRate: &never}},
+	}})
+	defer stopPods(p)
+
+	pod := newTestPod("web", "nginx")
+	pod.Spec.Containers[0].LivenessProbe = &v1.Probe{PeriodSeconds: 1, FailureThreshold: 1}
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+
+	pod = waitForPod(t, ch, func(pod *v1.Pod) bool {
+		return pod.Status.ContainerStatuses[0].RestartCount == 1
+	})
+	last := pod.Status.ContainerStatuses[0].LastTerminationState.Terminated
+	if last == nil || last.ExitCode != exitCodeKilled {
+		t.Fatalf("expected container to be killed by its liveness probe, got %+v", last)
+	}
+}
+
+func TestStartupProbeAnnotation(t *testing.T) {
+	pod := newTestPod("web", "nginx")
+	pod.Annotations = map[string]string{annotationStartupProbePrefix + "nginx": `{"periodSeconds":`}
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	if failed := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodFailed }); failed.Status.Reason != reasonInvalidAnnotations {
+		t.Fatalf("expected the pod to fail because of its annotations, got %q", failed.Status.Reason)
+	}
+
+	pod.Annotations[annotationStartupProbePrefix+"nginx"] = `{"periodSeconds":5,"failureThreshold":30}`
+	probe, err := startupProbe(pod, "nginx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if probe.PeriodSeconds != 5 || probe.FailureThreshold != 30 {
+		t.Fatalf("unexpected startup probe %+v", probe)
+	}
+}
+
+func TestReadyAnnotation(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+
+	pod := newTestPod("web", "nginx")
+	pod.Annotations = map[string]string{annotationReady: "false"}
+	if err := p.CreatePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	running := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodRunning })
+	if isReady(running) {
+		t.Fatal("expected pod to stay unready")
+	}
+
+	pod.Annotations[annotationReady] = "true"
+	if err := p.UpdatePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, isReady)
+}
+
+func TestStuckTerminatingAnnotation(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+
+	pod := newTestPod("web", "nginx")
+	pod.Annotations = map[string]string{annotationStuckTerminating: "true"}
+	if err := p.CreatePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, isReady)
+
+	if err := p.DeletePod(context.Background(), pod.DeepCopy()); err == nil {
+		t.Fatal("expected the deletion of a pod stuck terminating to fail")
+	}
+	stuck, err := p.GetPod(context.Background(), "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stuck.Status.Phase != v1.PodRunning {
+		t.Fatalf("expected pod to keep running, got %s", stuck.Status.Phase)
+	}
+
+	delete(pod.Annotations, annotationStuckTerminating)
+	if err := p.DeletePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyAnnotations(t *testing.T) {
+	var b Behavior
+	err := applyAnnotations(&b, map[string]string{
+		annotationStartDelay: "30s",
+		annotationFailAfter:  "1m",
+		annotationExitCode:   "3",
+		annotationCPUUsage:   "250m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Startup.Started.Value != 30*time.Second || b.Crash.After.Value != time.Minute {
+		t.Fatalf("unexpected durations %+v %+v", b.Startup.Started, b.Crash.After)
+	}
+	if b.Crash.ExitCode != 3 || b.Job.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d and %d", b.Crash.ExitCode, b.Job.ExitCode)
+	}
+	if usage := b.Usage.cpuNanoCores; usage == nil || *usage != 250000000 {
+		t.Fatalf("expected 250m CPU usage, got %v nanocores", usage)
+	}
+
+	for annotation, value := range map[string]string{
+		annotationFailAfter:        "soon",
+		annotationReady:            "maybe",
+		annotationStuckTerminating: "yes please",
+		annotationCPUUsage:         "-1",
+	} {
+		if err := applyAnnotations(&Behavior{}, map[string]string{annotation: value}); err == nil {
+			t.Fatalf("expected %s=%q to be rejected", annotation, value)
+		}
+	}
+
+	// Exit code 0 is honored for containers that complete.
+	b = Behavior{}
+	if err := applyAnnotations(&b, map[string]string{annotationRunDuration: "1m", annotationExitCode: "0"}); err != nil || b.Job.ExitCode != 0 {
+		t.Fatalf("expected containers to complete with exit code 0, got %d and %v", b.Job.ExitCode, err)
+	}
+}
+
+func TestDeletePodRunsPreStopHook(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+
+	pod := newTestPod("web", "nginx")
+	pod.Spec.Containers[0].Lifecycle = &v1.Lifecycle{
+		PreStop: &v1.Handler{Exec: &v1.ExecAction{Command: []string{"/bin/sh", "-c", "sleep 0.2"}}},
+	}
+	if err := p.CreatePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, isReady)
+
+	start := time.Now()
+	if err := p.DeletePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	terminating := <-ch
+	if terminating.Status.ContainerStatuses[0].State.Running == nil {
+		t.Fatalf("expected container to keep running while terminating, got %+v", terminating.Status.ContainerStatuses[0].State)
+	}
+
+	deleted := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodSucceeded })
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("expected the preStop hook to delay termination, took %s", elapsed)
+	}
+	if terminated := deleted.Status.ContainerStatuses[0].State.Terminated; terminated == nil || terminated.ExitCode != 0 {
+		t.Fatalf("expected container to stop gracefully, got %+v", terminated)
+	}
+	if _, err := p.GetPod(context.Background(), "default", "web"); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected pod to be gone, got %v", err)
+	}
+}
+
+func TestDeletePodIgnoringSIGTERM(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{
+		Termination: TerminationConfig{IgnoreSIGTERM: true},
+	}})
+	defer stopPods(p)
+
+	grace := int64(1)
+	pod := newTestPod("web", "nginx")
+	pod.Spec.TerminationGracePeriodSeconds = &grace
+	if err := p.CreatePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, isReady)
+
+	start := time.Now()
+	if err := p.DeletePod(context.Background(), pod.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	deleted := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Status.Phase == v1.PodSucceeded })
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected container to run for the whole grace period, took %s", elapsed)
+	}
+	if terminated := deleted.Status.ContainerStatuses[0].State.Terminated; terminated == nil || terminated.ExitCode != exitCodeKilled {
+		t.Fatalf("expected container to be killed, got %+v", terminated)
+	}
+}
@@ -0,0 +1,158 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// maxNodeImages is the number of images reported in the node status, like the kubelet's
+// default.
+const maxNodeImages = 50
+
+// Ping checks that the kubelet is healthy. It fails while a ping failure is set, which
+// stops the node lease from being renewed until the node is eventually marked NotReady.
+func (p *MockProvider) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pingFailure != "" {
+		return errors.New(p.pingFailure)
+	}
+	return nil
+}
+
+// SetPingFailure makes Ping fail with the message, and the node status stop being pushed,
+// as if the kubelet was unhealthy. An empty message makes the kubelet healthy again.
+func (p *MockProvider) SetPingFailure(ctx context.Context, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pingFailure = message
+	p.notifyNodeStatus()
+}
+
+// NotifyNodeStatus sets the callback the node status is pushed to whenever it changes: its
+// conditions, capacity, allocatable or images. Changes are pushed from their own goroutine
+// until ctx is done, so the provider never waits on the node controller, and the changes
+// made in the meantime are pushed at once.
+func (p *MockProvider) NotifyNodeStatus(ctx context.Context, cb func(*v1.Node)) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.nodeChanged:
+			}
+			p.mu.Lock()
+			var node *v1.Node
+			if p.pingFailure == "" {
+				node = p.nodeStatus()
+			}
+			p.mu.Unlock()
+			if node != nil {
+				cb(node)
+			}
+		}
+	}()
+}
+
+// notifyNodeStatus schedules a push of the node status without blocking.
+func (p *MockProvider) notifyNodeStatus() {
+	select {
+	case p.nodeChanged <- struct{}{}:
+	default:
+	}
+}
+
+// nodeStatus returns a copy of the node with its current status, or nil if the node has
+// not been configured yet.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) nodeStatus() *v1.Node {
+	if p.node == nil {
+		return nil
+	}
+	node := p.node.DeepCopy()
+	node.Status.Capacity = p.capacity.DeepCopy()
+	node.Status.Allocatable = p.allocatable.DeepCopy()
+	node.Status.Conditions = p.nodeConditions()
+	node.Status.Images = p.nodeImages()
+	return node
+}
+
+// nodeImages returns the images pulled onto the node.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) nodeImages() []v1.ContainerImage {
+	var names []string
+	for image := range p.images {
+		names = append(names, image)
+	}
+	sort.Strings(names)
+	if len(names) > maxNodeImages {
+		names = names[:maxNodeImages]
+	}
+	var images []v1.ContainerImage
+	for _, name := range names {
+		images = append(images, v1.ContainerImage{Names: []string{name}})
+	}
+	return images
+}
+
+// SetNodeResources changes the capacity and the allocatable of the node. Only the
+// resources listed are changed; the allocatable of a resource defaults to its capacity.
+// Pods already running are left alone, but new pods are admitted against the new
+// allocatable.
+func (p *MockProvider) SetNodeResources(ctx context.Context, capacity, allocatable v1.ResourceList) error {
+	for _, list := range []v1.ResourceList{capacity, allocatable} {
+		for name, q := range list {
+			if q.Sign() < 0 {
+				return errdefs.InvalidInputf("invalid quantity %s of %s", q.String(), name)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	newCapacity := p.capacity.DeepCopy()
+	newAllocatable := p.allocatable.DeepCopy()
+	for name, q := range capacity {
+		newCapacity[name] = q.DeepCopy()
+		if _, ok := allocatable[name]; !ok {
+			newAllocatable[name] = q.DeepCopy()
+		}
+	}
+	for name, q := range allocatable {
+		newAllocatable[name] = q.DeepCopy()
+	}
+	for name, q := range newAllocatable {
+		if c, ok := newCapacity[name]; ok && q.Cmp(c) > 0 {
+			return errdefs.InvalidInputf("allocatable %s of %s is more than its capacity %s", q.String(), name, c.String())
+		}
+	}
+	p.capacity, p.allocatable = newCapacity, newAllocatable
+	p.notifyNodeStatus()
+	return nil
+}
+
+// configuredCapacity returns the capacity of the node in the provider configuration.
+func configuredCapacity(config MockConfig) (v1.ResourceList, error) {
+	capacity := v1.ResourceList{}
+	for name, v := range map[v1.ResourceName]string{
+		v1.ResourceCPU:              config.CPU,
+		v1.ResourceMemory:           config.Memory,
+		v1.ResourcePods:             config.Pods,
+		v1.ResourceEphemeralStorage: config.EphemeralStorage,
+	} {
+		q, err := resource.ParseQuantity(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %v", name, v)
+		}
+		capacity[name] = q
+	}
+	return capacity, nil
+}
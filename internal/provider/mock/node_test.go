@@ -0,0 +1,87 @@
+package mock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNotifyNodeStatus(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Memory: "8Gi"})
+	defer stopPods(p)
+	p.ConfigureNode(context.Background(), &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "mocklet", Labels: map[string]string{}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nodes := make(chan *v1.Node, 10)
+	p.NotifyNodeStatus(ctx, func(node *v1.Node) { nodes <- node })
+	waitForNode := func(cond func(*v1.Node) bool) *v1.Node {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case node := <-nodes:
+				if cond(node) {
+					return node
+				}
+			case <-timeout:
+				t.Fatal("timed out waiting for node status")
+			}
+		}
+	}
+
+	if err := p.SetNodeCondition(ctx, v1.NodeCondition{Type: "KernelDeadlock", Status: v1.ConditionTrue}); err != nil {
+		t.Fatal(err)
+	}
+	waitForNode(func(node *v1.Node) bool { return hasCondition(node.Status.Conditions, "KernelDeadlock") })
+
+	if err := p.SetNodeResources(ctx, v1.ResourceList{v1.ResourceMemory: resource.MustParse("4Gi")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	node := waitForNode(func(node *v1.Node) bool { return node.Status.Capacity.Memory().String() == "4Gi" })
+	if node.Status.Allocatable.Memory().String() != "4Gi" || node.Status.Capacity.Cpu().String() != defaultCPUCapacity {
+		t.Fatalf("expected only the memory to change, got capacity %v and allocatable %v", node.Status.Capacity, node.Status.Allocatable)
+	}
+	err := p.SetNodeResources(ctx, nil, v1.ResourceList{v1.ResourceMemory: resource.MustParse("5Gi")})
+	if !errdefs.IsInvalidInput(err) {
+		t.Fatalf("expected allocatable above capacity to be rejected, got %v", err)
+	}
+
+	// New pods are admitted against the new allocatable.
+	pod := newTestPod("big", "app")
+	pod.Spec.Containers[0].Resources.Requests = v1.ResourceList{v1.ResourceMemory: resource.MustParse("6Gi")}
+	if err := p.CreatePod(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if pod := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "big" }); pod.Status.Reason != "OutOfmemory" {
+		t.Fatalf("expected the pod to be rejected with OutOfmemory, got %q", pod.Status.Reason)
+	}
+
+	if err := p.CreatePod(ctx, newTestPod("nginx", "nginx")); err != nil {
+		t.Fatal(err)
+	}
+	waitForNode(func(node *v1.Node) bool { return len(node.Status.Images) == 1 && node.Status.Images[0].Names[0] == "busybox" })
+
+	// An unhealthy kubelet fails its pings and stops pushing the node status.
+	p.SetPingFailure(ctx, "PLEG is not healthy")
+	if err := p.Ping(ctx); err == nil || err.Error() != "PLEG is not healthy" {
+		t.Fatalf("expected ping to fail, got %v", err)
+	}
+	if err := p.SetNodeCondition(ctx, v1.NodeCondition{Type: "KernelDeadlock", Status: v1.ConditionFalse}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case node := <-nodes:
+		t.Fatalf("expected no node status while the kubelet is unhealthy, got %v", node.Status.Conditions)
+	case <-time.After(100 * time.Millisecond):
+	}
+	p.SetPingFailure(ctx, "")
+	if err := p.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForNode(func(node *v1.Node) bool { return !hasCondition(node.Status.Conditions, "KernelDeadlock") })
+}
@@ -0,0 +1,256 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Exit code of containers killed by the kubelet after failing their liveness or startup probe.
+const exitCodeKilled = 137
+
+// Probe defaults applied by the API server.
+const (
+	defaultProbePeriodSeconds    = 10
+	defaultProbeSuccessThreshold = 1
+	defaultProbeFailureThreshold = 3
+)
+
+// ProbeConfig decides the outcome of the probes of simulated containers. Probe timing
+// (initialDelaySeconds, periodSeconds, successThreshold and failureThreshold) comes
+// from the container spec.
+type ProbeConfig struct {
+	Readiness ProbeOutcome `yaml:"readiness,omitempty"`
+	Liveness  ProbeOutcome `yaml:"liveness,omitempty"`
+	Startup   ProbeOutcome `yaml:"startup,omitempty"`
+}
+
+// ProbeOutcome decides whether a probe succeeds.
+type ProbeOutcome struct {
+	// SuccessRate is the chance, between 0 and 1, that a probe succeeds. Defaults to 1.
+	SuccessRate *float64 `yaml:"successRate,omitempty"`
+	// Schedule changes the success rate over the life of a container. Each window
+	// applies from the given time since the container started until the next one.
+	Schedule []ProbeWindow `yaml:"schedule,omitempty"`
+}
+
+// ProbeWindow sets the success rate of probes from a point in the life of a container.
+type ProbeWindow struct {
+	After       time.Duration `yaml:"after"`
+	SuccessRate float64       `yaml:"successRate"`
+}
+
+func (c ProbeConfig) validate() error {
+	for name, o := range map[string]ProbeOutcome{
+		"readiness": c.Readiness,
+		"liveness":  c.Liveness,
+		"startup":   c.Startup,
+	} {
+		if err := o.validate(); err != nil {
+			return fmt.Errorf("invalid probes.%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (o ProbeOutcome) validate() error {
+	if o.SuccessRate != nil && (*o.SuccessRate < 0 || *o.SuccessRate > 1) {
+		return fmt.Errorf("success rate %v is not between 0 and 1", *o.SuccessRate)
+	}
+	var prev time.Duration
+	for _, w := range o.Schedule {
+		if w.After < prev {
+			return fmt.Errorf("schedule windows must be in increasing order")
+		}
+		if w.SuccessRate < 0 || w.SuccessRate > 1 {
+			return fmt.Errorf("success rate %v is not between 0 and 1", w.SuccessRate)
+		}
+		prev = w.After
+	}
+	return nil
+}
+
+// succeeds draws the outcome of a probe of a container that has been running for d.
+func (o ProbeOutcome) succeeds(d time.Duration) bool {
+	rate := 1.0
+	if o.SuccessRate != nil {
+		rate = *o.SuccessRate
+	}
+	for _, w := range o.Schedule {
+		if d < w.After {
+			break
+		}
+		rate = w.SuccessRate
+	}
+	return rand.Float64() < rate
+}
+
+// startupProbe returns the startup probe of the named container, if one is set with
+// the startup probe annotation.
+func startupProbe(pod *v1.Pod, container string) (*v1.Probe, error) {
+	data, ok := pod.Annotations[annotationStartupProbePrefix+container]
+	if !ok {
+		return nil, nil
+	}
+	probe := &v1.Probe{}
+	if err := json.Unmarshal([]byte(data), probe); err != nil {
+		return nil, fmt.Errorf("%s%s: %v", annotationStartupProbePrefix, container, err)
+	}
+	return probe, nil
+}
+
+type probeType int
+
+const (
+	readiness probeType = iota
+	liveness
+	startup
+)
+
+// prober runs one probe of a container run and counts its consecutive results.
+type prober struct {
+	probeType probeType
+	probe     v1.Probe
+	outcome   ProbeOutcome
+	successes int32
+	failures  int32
+}
+
+func newProber(t probeType, probe *v1.Probe, outcome ProbeOutcome) *prober {
+	pr := &prober{probeType: t, probe: *probe, outcome: outcome}
+	if pr.probe.PeriodSeconds <= 0 {
+		pr.probe.PeriodSeconds = defaultProbePeriodSeconds
+	}
+	if pr.probe.SuccessThreshold <= 0 {
+		pr.probe.SuccessThreshold = defaultProbeSuccessThreshold
+	}
+	if pr.probe.FailureThreshold <= 0 {
+		pr.probe.FailureThreshold = defaultProbeFailureThreshold
+	}
+	return pr
+}
+
+func (pr *prober) initialDelay() time.Duration {
+	return time.Duration(pr.probe.InitialDelaySeconds) * time.Second
+}
+
+func (pr *prober) period() time.Duration {
+	return time.Duration(pr.probe.PeriodSeconds) * time.Second
+}
+
+// startProbes starts probing a newly started app container. A startup probe holds back
+// the readiness and liveness probes until it succeeds. Containers without a readiness
+// probe become ready once started, after the configured readiness delay.
+//
+// A readiness forced by the pod's behavior overrides both.
+func (p *MockProvider) startProbes(mp *mockPod, ref containerRef, now metav1.Time) {
+	pod := mp.pod
+	probes := mp.behavior.Probes
+	if probe, _ := startupProbe(pod, ref.container(pod).Name); probe != nil {
+		pr := newProber(startup, probe, probes.Startup)
+		p.runProber(mp, ref, pr, pr.initialDelay())
+		return
+	}
+	p.startedProbes(mp, ref, now)
+}
+
+// startedProbes starts the readiness and liveness probes of a started container. Forced
+// readiness takes effect right away.
+func (p *MockProvider) startedProbes(mp *mockPod, ref containerRef, now metav1.Time) {
+	pod := mp.pod
+	container := ref.container(pod)
+	probes := mp.behavior.Probes
+	if container.LivenessProbe != nil {
+		pr := newProber(liveness, container.LivenessProbe, probes.Liveness)
+		p.runProber(mp, ref, pr, pr.initialDelay())
+	}
+	if container.ReadinessProbe != nil {
+		pr := newProber(readiness, container.ReadinessProbe, probes.Readiness)
+		p.runProber(mp, ref, pr, pr.initialDelay())
+	} else {
+		p.afterRun(mp, ref, mp.behavior.Startup.Ready.Sample(), func(cs *v1.ContainerStatus, now metav1.Time) bool {
+			if mp.behavior.Ready != nil || cs.Ready {
+				return false
+			}
+			cs.Ready = true
+			syncPodStatus(pod, now)
+			return true
+		})
+	}
+	if ready := mp.behavior.Ready; ready != nil {
+		ref.status(pod).Ready = *ready
+		syncPodStatus(pod, now)
+	}
+}
+
+// runProber schedules the next probe of the container run after d, for as long as the
+// run lasts. Failing startup and liveness probes get the container killed; readiness
+// probes flip the container's readiness once their threshold is reached.
+func (p *MockProvider) runProber(mp *mockPod, ref containerRef, pr *prober, d time.Duration) {
+	p.afterRun(mp, ref, d, func(cs *v1.ContainerStatus, now metav1.Time) bool {
+		if pr.outcome.succeeds(now.Sub(cs.State.Running.StartedAt.Time)) {
+			pr.successes++
+			pr.failures = 0
+		} else {
+			pr.failures++
+			pr.successes = 0
+		}
+
+		changed := false
+		switch pr.probeType {
+		case startup:
+			if pr.successes >= 1 {
+				p.startedProbes(mp, ref, now)
+				return mp.behavior.Ready != nil
+			}
+			if pr.failures >= pr.probe.FailureThreshold {
+				p.exitContainer(mp, ref, exitCodeKilled, reasonError, "", now)
+				return true
+			}
+		case liveness:
+			if pr.failures >= pr.probe.FailureThreshold {
+				p.exitContainer(mp, ref, exitCodeKilled, reasonError, "", now)
+				return true
+			}
+		case readiness:
+			switch {
+			case mp.behavior.Ready != nil:
+			case !cs.Ready && pr.successes >= pr.probe.SuccessThreshold:
+				cs.Ready = true
+				changed = true
+			case cs.Ready && pr.failures >= pr.probe.FailureThreshold:
+				cs.Ready = false
+				changed = true
+			}
+		}
+		if changed {
+			syncPodStatus(mp.pod, now)
+		}
+		p.runProber(mp, ref, pr, pr.period())
+		return changed
+	})
+}
+
+// forceReadiness applies the readiness forced by the pod's behavior to its started
+// containers. When readiness is no longer forced, containers without a readiness probe
+// are ready again and the others wait for their next probes.
+func forceReadiness(mp *mockPod, now metav1.Time) {
+	pod := mp.pod
+	for i := range pod.Status.ContainerStatuses {
+		cs := &pod.Status.ContainerStatuses[i]
+		if cs.State.Running == nil {
+			continue
+		}
+		switch {
+		case mp.behavior.Ready != nil:
+			cs.Ready = *mp.behavior.Ready
+		case pod.Spec.Containers[i].ReadinessProbe == nil:
+			cs.Ready = true
+		}
+	}
+	syncPodStatus(pod, now)
+}
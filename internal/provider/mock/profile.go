@@ -0,0 +1,167 @@
+package mock
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Profile applies its own behavior to the pods it selects. Settings left out of a profile
+// are inherited from the node, while the ones it sets override the node's, zero values
+// included: "logs: {rate: 0}" turns logs off for the pods of the profile. Profiles built
+// in code rather than decoded from the provider config only override the node with their
+// non-zero settings. Unlike the node's job settings, a profile's apply to the pods it
+// selects whether or not they are owned by a Job.
+type Profile struct {
+	Name string `yaml:"name,omitempty"`
+	// Selector is a label selector over the pod labels, e.g. "app=db" or "tier in (batch)".
+	Selector string `yaml:"selector,omitempty"`
+	// Namespaces restricts the profile to pods in these namespaces.
+	Namespaces []string `yaml:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector over the labels of the pod's namespace, e.g.
+	// "team=payments". It restricts the profile to the namespaces it selects.
+	NamespaceSelector string `yaml:"namespaceSelector,omitempty"`
+
+	Behavior `yaml:",inline"`
+
+	selector          labels.Selector
+	namespaceSelector labels.Selector
+	// completes is set if the profile makes its pods run to completion, Job or not.
+	completes bool
+	// set holds the keys the profile was decoded from, or nil if it was built in code.
+	set map[interface{}]interface{}
+}
+
+// profile is used to decode a Profile without recursing into Profile.UnmarshalYAML.
+type profile Profile
+
+// UnmarshalYAML decodes the profile and records the settings it sets.
+func (p *Profile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal((*profile)(p)); err != nil {
+		return err
+	}
+	return unmarshal(&p.set)
+}
+
+// compile validates the profile and resolves its behavior against the node defaults.
+func (p *Profile) compile(defaults Behavior) error {
+	if p.Selector == "" && len(p.Namespaces) == 0 && p.NamespaceSelector == "" {
+		return fmt.Errorf("one of selector, namespaces or namespaceSelector is required")
+	}
+	selector, err := labels.Parse(p.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %v", err)
+	}
+	p.selector = selector
+	p.namespaceSelector = nil
+	if p.NamespaceSelector != "" {
+		if p.namespaceSelector, err = labels.Parse(p.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid namespaceSelector: %v", err)
+		}
+	}
+	p.completes = !p.Behavior.Job.Duration.IsZero()
+	p.Behavior = mergeBehavior(defaults, p.Behavior, p.set)
+	return p.Behavior.validate()
+}
+
+// matches reports whether the profile selects the pod, whose namespace has the given
+// labels, or nil ones if the namespace is not known.
+func (p *Profile) matches(pod *v1.Pod, namespaceLabels labels.Set) bool {
+	if len(p.Namespaces) > 0 && !containsString(p.Namespaces, pod.Namespace) {
+		return false
+	}
+	if p.namespaceSelector != nil && (namespaceLabels == nil || !p.namespaceSelector.Matches(namespaceLabels)) {
+		return false
+	}
+	return p.selector.Matches(labels.Set(pod.Labels))
+}
+
+// compileProfiles resolves the profiles of the node configuration.
+func (c *MockConfig) compileProfiles() error {
+	c.Profiles = append([]Profile(nil), c.Profiles...)
+	for i := range c.Profiles {
+		if err := c.Profiles[i].compile(c.Behavior); err != nil {
+			return fmt.Errorf("invalid profiles[%d] %q: %v", i, c.Profiles[i].Name, err)
+		}
+		if c.Profiles[i].namespaceSelector != nil {
+			c.selectsNamespaces = true
+		}
+	}
+	return nil
+}
+
+// profileFor returns the first profile selecting the pod, whose namespace has the given
+// labels, or nil if there is none.
+func (c *MockConfig) profileFor(pod *v1.Pod, namespaceLabels labels.Set) *Profile {
+	for i := range c.Profiles {
+		if c.Profiles[i].matches(pod, namespaceLabels) {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// mergeBehavior returns base with the settings of override that are set: the ones whose
+// keys are in set, or the non-zero ones if set is nil. Distributions, usage models, lists
+// and pointers are replaced as a whole, other settings one field at a time.
+func mergeBehavior(base, override Behavior, set map[interface{}]interface{}) Behavior {
+	merged := reflect.New(reflect.TypeOf(base)).Elem()
+	merged.Set(reflect.ValueOf(base))
+	mergeValue(merged, reflect.ValueOf(override), set)
+	return merged.Interface().(Behavior)
+}
+
+var (
+	distributionType = reflect.TypeOf(Distribution{})
+	usageModelType   = reflect.TypeOf(UsageModel{})
+)
+
+// mergeValue merges the fields of the struct src into dst.
+func mergeValue(dst, src reflect.Value, set map[interface{}]interface{}) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		// Unexported fields are derived from the others when the behavior is validated.
+		if field.PkgPath != "" {
+			continue
+		}
+		d, s := dst.Field(i), src.Field(i)
+		whole := s.Kind() != reflect.Struct || s.Type() == distributionType || s.Type() == usageModelType
+		if set == nil {
+			if !whole {
+				mergeValue(d, s, nil)
+			} else if !s.IsZero() {
+				d.Set(s)
+			}
+			continue
+		}
+		value, ok := set[yamlKey(field)]
+		if !ok {
+			continue
+		}
+		if nested, isMap := value.(map[interface{}]interface{}); !whole && isMap {
+			mergeValue(d, s, nested)
+			continue
+		}
+		d.Set(s)
+	}
+}
+
+// yamlKey returns the key of a struct field in YAML documents.
+func yamlKey(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package mock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VineethReddy02/mocklet/manager"
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const profilesConfig = `
+startup:
+  started: 2s
+  ready: 1s
+usage:
+  cpu: 100m
+profiles:
+- name: db
+  selector: app=db
+  startup:
+    started: 60s
+  usage:
+    memory: 2Gi
+- name: batch
+  selector: tier=batch
+  namespaces: [jobs]
+  job:
+    duration: 5m
+`
+
+func TestProfiles(t *testing.T) {
+	var config MockConfig
+	if err := yaml.Unmarshal([]byte(profilesConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := newTestProvider(t, config)
+	if p.ReadsNamespaces() {
+		t.Fatal("expected the provider not to read namespaces without a namespaceSelector")
+	}
+
+	behaviorFor := func(namespace string, labels map[string]string) Behavior {
+		t.Helper()
+		b, err := p.behaviorFor(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "pod", Labels: labels}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	db := behaviorFor("default", map[string]string{"app": "db"})
+	if db.Startup.Started.Value != 60*time.Second || db.Startup.Ready.Value != time.Second {
+		t.Fatalf("expected db pods to start in 60s and inherit the node readiness delay, got %+v", db.Startup)
+	}
+	if cpu, memory := db.Usage.cpuNanoCores, db.Usage.memoryBytes; cpu == nil || *cpu != 100000000 || memory == nil || *memory != 2<<30 {
+		t.Fatalf("expected db pods to use 100m CPU and 2Gi memory, got %v and %v", cpu, memory)
+	}
+
+	if batch := behaviorFor("jobs", map[string]string{"tier": "batch"}); batch.Job.Duration.Value != 5*time.Minute {
+		t.Fatalf("expected batch pods to complete after 5m, got %+v", batch.Job)
+	}
+	if other := behaviorFor("default", map[string]string{"tier": "batch"}); !other.Job.Duration.IsZero() || other.Startup.Started.Value != 2*time.Second {
+		t.Fatalf("expected batch pods outside the jobs namespace to get the node behavior, got %+v", other)
+	}
+}
+
+func TestProfileValidation(t *testing.T) {
+	for _, profile := range []Profile{
+		{Name: "no selector"},
+		{Name: "bad selector", Selector: "app in ("},
+		{Name: "bad namespace selector", NamespaceSelector: "team in ("},
+		{Name: "bad usage", Selector: "app=db", Behavior: Behavior{Usage: UsageConfig{Memory: "lots"}}},
+	} {
+		config := MockConfig{Profiles: []Profile{profile}}
+		if _, err := NewMockProviderMockConfig(config, "mocklet", "Linux", "10.0.0.1", 10250); err == nil {
+			t.Fatalf("expected profile %q to be rejected", profile.Name)
+		}
+	}
+}
+
+const namespaceProfilesConfig = `
+logs:
+  rate: 5
+termination:
+  ignoreSIGTERM: true
+  shutdown: 2s
+profiles:
+- name: quiet
+  namespaceSelector: team=payments
+  logs:
+    rate: 0
+  termination:
+    ignoreSIGTERM: false
+`
+
+func TestProfileZeroValuesAndNamespaceSelector(t *testing.T) {
+	var config MockConfig
+	if err := yaml.Unmarshal([]byte(namespaceProfilesConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := newTestProvider(t, config)
+	if !p.ReadsNamespaces() {
+		t.Fatal("expected the provider to read namespaces for the namespaceSelector")
+	}
+	namespaces := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name, team := range map[string]string{"checkout": "payments", "search": "discovery"} {
+		if err := namespaces.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rm, err := manager.NewResourceManager(nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm.SetNamespaceLister(corev1listers.NewNamespaceLister(namespaces))
+	p.resourceManager = rm
+
+	behaviorFor := func(namespace string) Behavior {
+		t.Helper()
+		b, err := p.behaviorFor(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "pod"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// Zero values set by the profile override the node's, and the settings it leaves out
+	// are inherited.
+	if quiet := behaviorFor("checkout"); quiet.Logs.Rate != 0 || quiet.Termination.IgnoreSIGTERM || quiet.Termination.Shutdown.Value != 2*time.Second {
+		t.Fatalf("expected the profile to turn logs off and honor SIGTERM, got %+v and %+v", quiet.Logs, quiet.Termination)
+	}
+	for _, namespace := range []string{"search", "unknown"} {
+		if b := behaviorFor(namespace); b.Logs.Rate != 5 || !b.Termination.IgnoreSIGTERM {
+			t.Fatalf("expected pods in %s to get the node behavior, got %+v", namespace, b.Logs)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons reported by the kubelet for pods whose references cannot be resolved.
+const (
+	reasonCreateContainerConfigError = "CreateContainerConfigError"
+	eventFailedMount                 = "FailedMount"
+	eventFailed                      = "Failed"
+)
+
+// mountTimeout is how long the kubelet waits for the volumes of a pod to be mounted before
+// it gives up on the pod sync and reports the volumes that are not.
+const mountTimeout = 2 * time.Minute
+
+// mountRetryDelay is how long the kubelet waits before mounting a volume again after it
+// failed to. A variable so tests can shorten it.
+var mountRetryDelay = 2 * time.Second
+
+// heldPodsInterval is how often the pods the pod controller holds back are looked for. A
+// variable so tests can shorten it.
+var heldPodsInterval = 5 * time.Second
+
+// volumeError is a volume of a pod that cannot be mounted.
+type volumeError struct {
+	volume  string
+	message string
+	// claim is set for claims, which the kubelet does not report until it gives up.
+	claim bool
+}
+
+// volumeErrors returns the volumes of the pod that reference a ConfigMap, a Secret or a key
+// of one that does not exist and are not optional, or a PersistentVolumeClaim that does not
+// exist or is not bound. Nothing is checked without a resource manager.
+func (p *MockProvider) volumeErrors(pod *v1.Pod) []volumeError {
+	if p.resourceManager == nil {
+		return nil
+	}
+	var errs []volumeError
+	for _, volume := range pod.Spec.Volumes {
+		var message string
+		claim := false
+		switch {
+		case volume.ConfigMap != nil:
+			message = p.configMapVolumeError(pod.Namespace, volume.ConfigMap.Name, volume.ConfigMap.Items, volume.ConfigMap.Optional)
+		case volume.Secret != nil:
+			message = p.secretVolumeError(pod.Namespace, volume.Secret.SecretName, volume.Secret.Items, volume.Secret.Optional)
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				switch {
+				case source.ConfigMap != nil:
+					message = p.configMapVolumeError(pod.Namespace, source.ConfigMap.Name, source.ConfigMap.Items, source.ConfigMap.Optional)
+				case source.Secret != nil:
+					message = p.secretVolumeError(pod.Namespace, source.Secret.Name, source.Secret.Items, source.Secret.Optional)
+				}
+				if message != "" {
+					break
+				}
+			}
+		case volume.PersistentVolumeClaim != nil:
+			claim = true
+			message = p.claimError(pod.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		}
+		if message != "" {
+			errs = append(errs, volumeError{volume: volume.Name, message: message, claim: claim})
+		}
+	}
+	return errs
+}
+
+func (p *MockProvider) configMapVolumeError(namespace, name string, items []v1.KeyToPath, optional *bool) string {
+	cm, err := p.resourceManager.GetConfigMap(name, namespace)
+	if err != nil {
+		if isOptional(optional) {
+			return ""
+		}
+		return fmt.Sprintf("configmap %q not found", name)
+	}
+	for _, item := range items {
+		_, ok := cm.Data[item.Key]
+		if _, binary := cm.BinaryData[item.Key]; !ok && !binary && !isOptional(optional) {
+			return fmt.Sprintf("configmap references non-existent config key: %s", item.Key)
+		}
+	}
+	return ""
+}
+
+func (p *MockProvider) secretVolumeError(namespace, name string, items []v1.KeyToPath, optional *bool) string {
+	secret, err := p.resourceManager.GetSecret(name, namespace)
+	if err != nil {
+		if isOptional(optional) {
+			return ""
+		}
+		return fmt.Sprintf("secret %q not found", name)
+	}
+	for _, item := range items {
+		if _, ok := secret.Data[item.Key]; !ok && !isOptional(optional) {
+			return fmt.Sprintf("references non-existent secret key: %s", item.Key)
+		}
+	}
+	return ""
+}
+
+func (p *MockProvider) claimError(namespace, name string) string {
+	claim, err := p.resourceManager.GetPersistentVolumeClaim(name, namespace)
+	if err != nil {
+		return fmt.Sprintf("error processing PVC %s/%s: failed to fetch PVC from API server: persistentvolumeclaims %q not found", namespace, name, name)
+	}
+	if claim.Status.Phase != v1.ClaimBound || claim.Spec.VolumeName == "" {
+		return fmt.Sprintf("error processing PVC %s/%s: PVC %s/%s has non-bound phase (%q) or empty pvc.Spec.VolumeName (%q)",
+			namespace, name, namespace, name, claim.Status.Phase, claim.Spec.VolumeName)
+	}
+	return ""
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// mountVolumes mounts the volumes of the pod before its sandbox is created, and reports
+// whether they could all be mounted. When some cannot, the pod stays in ContainerCreating
+// and, like the kubelet, mountVolumes records a FailedMount event for each of them and
+// retry runs again once mountRetryDelay has passed. Every mountTimeout the volumes that are
+// still not mounted are reported together.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) mountVolumes(mp *mockPod, now metav1.Time, retry func(now metav1.Time) bool) bool {
+	errs := p.volumeErrors(mp.pod)
+	if len(errs) == 0 {
+		mp.mountingSince = time.Time{}
+		return true
+	}
+	if mp.mountingSince.IsZero() {
+		mp.mountingSince = now.Time
+	}
+	var unmounted []string
+	var claimMessage string
+	for _, err := range errs {
+		unmounted = append(unmounted, err.volume)
+		if err.claim {
+			claimMessage = err.message
+			continue
+		}
+		p.eventf(mp.pod, v1.EventTypeWarning, eventFailedMount, "MountVolume.SetUp failed for volume %q : %s", err.volume, err.message)
+	}
+	if now.Sub(mp.mountingSince) >= mountTimeout {
+		var unattached []string
+		for _, volume := range mp.pod.Spec.Volumes {
+			unattached = append(unattached, volume.Name)
+		}
+		sort.Strings(unmounted)
+		sort.Strings(unattached)
+		message := "timed out waiting for the condition"
+		if claimMessage != "" {
+			message = claimMessage
+		}
+		p.eventf(mp.pod, v1.EventTypeWarning, eventFailedMount, "Unable to attach or mount volumes: unmounted volumes=[%s], unattached volumes=[%s]: %s",
+			strings.Join(unmounted, " "), strings.Join(unattached, " "), message)
+		mp.mountingSince = now.Time
+	}
+	p.after(mp, mountRetryDelay, retry)
+	return false
+}
+
+// resolveEnv returns the environment of the container, resolved from its env and envFrom
+// like the kubelet does before creating it. It fails like the kubelet when the container
+// references a ConfigMap, a Secret or a key of one that does not exist and is not optional.
+// Without a resource manager only the plain values and the fields of the pod are resolved.
+func (p *MockProvider) resolveEnv(pod *v1.Pod, c *v1.Container) ([]v1.EnvVar, error) {
+	var names []string
+	values := make(map[string]string)
+	set := func(name, value string) {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+
+	for _, from := range c.EnvFrom {
+		var data map[string][]byte
+		switch {
+		case from.ConfigMapRef != nil && p.resourceManager != nil:
+			if data = p.configMapData(pod.Namespace, from.ConfigMapRef.Name); data == nil && !isOptional(from.ConfigMapRef.Optional) {
+				return nil, fmt.Errorf("configmap %q not found", from.ConfigMapRef.Name)
+			}
+		case from.SecretRef != nil && p.resourceManager != nil:
+			if data = p.secretData(pod.Namespace, from.SecretRef.Name); data == nil && !isOptional(from.SecretRef.Optional) {
+				return nil, fmt.Errorf("secret %q not found", from.SecretRef.Name)
+			}
+		}
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			set(from.Prefix+key, string(data[key]))
+		}
+	}
+
+	for _, env := range c.Env {
+		from := env.ValueFrom
+		switch {
+		case from == nil:
+			set(env.Name, env.Value)
+		case from.FieldRef != nil:
+			set(env.Name, podFieldValue(pod, from.FieldRef.FieldPath))
+		case from.ResourceFieldRef != nil:
+			container := c
+			if name := from.ResourceFieldRef.ContainerName; name != "" {
+				if container = findPodContainer(pod, name); container == nil {
+					return nil, fmt.Errorf("container %q not found in pod", name)
+				}
+			}
+			set(env.Name, resourceFieldValue(container, from.ResourceFieldRef, p.allocatable))
+		case from.ConfigMapKeyRef != nil && p.resourceManager != nil:
+			ref := from.ConfigMapKeyRef
+			data := p.configMapData(pod.Namespace, ref.Name)
+			value, ok := data[ref.Key]
+			switch {
+			case ok:
+				set(env.Name, string(value))
+			case isOptional(ref.Optional):
+			case data == nil:
+				return nil, fmt.Errorf("configmap %q not found", ref.Name)
+			default:
+				return nil, fmt.Errorf("couldn't find key %s in ConfigMap %s/%s", ref.Key, pod.Namespace, ref.Name)
+			}
+		case from.SecretKeyRef != nil && p.resourceManager != nil:
+			ref := from.SecretKeyRef
+			data := p.secretData(pod.Namespace, ref.Name)
+			value, ok := data[ref.Key]
+			switch {
+			case ok:
+				set(env.Name, string(value))
+			case isOptional(ref.Optional):
+			case data == nil:
+				return nil, fmt.Errorf("secret %q not found", ref.Name)
+			default:
+				return nil, fmt.Errorf("couldn't find key %s in Secret %s/%s", ref.Key, pod.Namespace, ref.Name)
+			}
+		}
+	}
+
+	env := make([]v1.EnvVar, 0, len(names))
+	for _, name := range names {
+		env = append(env, v1.EnvVar{Name: name, Value: values[name]})
+	}
+	return env, nil
+}
+
+// hasEnvReferences reports whether the environment of the container is not resolved yet.
+func hasEnvReferences(c *v1.Container) bool {
+	if len(c.EnvFrom) > 0 {
+		return true
+	}
+	for _, env := range c.Env {
+		if env.ValueFrom != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// apiContainer returns the container as it is in the API. The pod controller resolves the
+// environment of containers before they reach the provider, so the references of the
+// container can only be checked again, such as when it restarts, in the pod from the API.
+// It falls back to the container of the provider's pod.
+func (p *MockProvider) apiContainer(mp *mockPod, ref containerRef) *v1.Container {
+	container := ref.container(mp.pod)
+	if p.resourceManager == nil {
+		return container
+	}
+	pod, err := p.resourceManager.GetPod(mp.pod.Name, mp.pod.Namespace)
+	if err != nil || pod.UID != mp.pod.UID {
+		return container
+	}
+	if c := findPodContainer(pod, container.Name); c != nil {
+		return c
+	}
+	return container
+}
+
+// createContainerConfig generates the configuration of the container before it is
+// created, and reports whether it could. When the environment of the container cannot be
+// resolved, the container waits in CreateContainerConfigError and is started again on the
+// next pod sync.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) createContainerConfig(mp *mockPod, ref containerRef, now metav1.Time) bool {
+	spec := p.apiContainer(mp, ref)
+	if !hasEnvReferences(spec) {
+		return true
+	}
+	env, err := p.resolveEnv(mp.pod, spec)
+	if err != nil {
+		cs := ref.status(mp.pod)
+		cs.State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reasonCreateContainerConfigError, Message: err.Error()}}
+		syncPodStatus(mp.pod, now)
+		p.eventf(mp.pod, v1.EventTypeWarning, eventFailed, "Error: %v", err)
+		p.after(mp, podSyncDelay, func(now metav1.Time) bool {
+			p.startContainer(mp, ref, now)
+			return true
+		})
+		return false
+	}
+	if container := ref.container(mp.pod); hasEnvReferences(container) {
+		container.Env, container.EnvFrom = env, nil
+	}
+	return true
+}
+
+// runHeldPodsSync takes over the pods the pod controller holds back every interval until
+// ctx is done.
+func (p *MockProvider) runHeldPodsSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.createHeldPods(ctx)
+		}
+	}
+}
+
+// createHeldPods creates the pods bound to the node whose containers reference a ConfigMap,
+// a Secret or a key of one that does not exist. The pod controller does not create them
+// until it can resolve their environment, while the kubelet starts them and reports their
+// containers in CreateContainerConfigError until it can. Once it can, the pod controller
+// finds the pods already created.
+//
+// Only pods still pending are taken over. The pod controller leaves the phase of the pods it
+// holds back alone, but pods it failed to create with RestartPolicy Never are marked Failed
+// with the reason ProviderFailed, and it never updates the status of those again, so
+// creating them would leave their status stale. They stay Failed, as they would with any
+// other provider, and are recreated by their owner.
+func (p *MockProvider) createHeldPods(ctx context.Context) {
+	for _, pod := range p.resourceManager.GetPods() {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodPending || len(pod.Status.ContainerStatuses) > 0 {
+			continue
+		}
+		key, err := buildKey(pod)
+		if err != nil {
+			continue
+		}
+		p.mu.Lock()
+		_, known := p.pods[key]
+		held := !known && p.holdsBack(pod)
+		p.mu.Unlock()
+		if !held {
+			continue
+		}
+		if err := p.CreatePod(ctx, pod.DeepCopy()); err != nil {
+			log.G(ctx).WithError(err).Warnf("Failed to create pod %s/%s held back by the pod controller", pod.Namespace, pod.Name)
+		}
+	}
+}
+
+// holdsBack reports whether the environment of a container of the pod cannot be resolved.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) holdsBack(pod *v1.Pod) bool {
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if _, err := p.resolveEnv(pod, &containers[i]); err != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventf records an event about the pod, if the provider has a recorder.
+func (p *MockProvider) eventf(pod *v1.Pod, eventType, reason, messageFmt string, args ...interface{}) {
+	if p.recorder != nil {
+		p.recorder.Eventf(pod, eventType, reason, messageFmt, args...)
+	}
+}
@@ -0,0 +1,135 @@
+package mock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VineethReddy02/mocklet/manager"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// waitForEvent returns the first recorded event that contains substr.
+func waitForEvent(t *testing.T, recorder *record.FakeRecorder, substr string) string {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, substr) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for an event with %q", substr)
+		}
+	}
+}
+
+func TestMissingReferences(t *testing.T) {
+	defer func(mount, sync, held time.Duration) {
+		mountRetryDelay, podSyncDelay, heldPodsInterval = mount, sync, held
+	}(mountRetryDelay, podSyncDelay, heldPodsInterval)
+	mountRetryDelay, podSyncDelay, heldPodsInterval = 10*time.Millisecond, 10*time.Millisecond, 10*time.Millisecond
+
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	claims := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	rm, err := manager.NewResourceManager(corev1listers.NewPodLister(pods), nil, corev1listers.NewConfigMapLister(configMaps), nil, corev1listers.NewPersistentVolumeClaimLister(claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewMockProviderMockConfig(MockConfig{}, "mocklet", "Linux", "10.0.0.1", 10250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopPods(p)
+	recorder := record.NewFakeRecorder(100)
+	p.resourceManager, p.recorder = rm, recorder
+	ch := make(chan *v1.Pod, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p.NotifyPods(ctx, func(pod *v1.Pod) {
+		ch <- pod
+	})
+
+	// Volumes that cannot be mounted keep the pod in ContainerCreating until they can.
+	pod := newTestPod("web", "app")
+	pod.Spec.Volumes = []v1.Volume{
+		{Name: "settings", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "settings"}}}},
+		{Name: "data", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+	}
+	if err := p.CreatePod(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitForEvent(t, recorder, "FailedMount"); event != `Warning FailedMount MountVolume.SetUp failed for volume "settings" : configmap "settings" not found` {
+		t.Fatalf("unexpected event %q", event)
+	}
+	settings := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "settings"}, Data: map[string]string{"level": "debug"}}
+	if err := configMaps.Add(settings); err != nil {
+		t.Fatal(err)
+	}
+	claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "data"}, Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending}}
+	if err := claims.Add(claim); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := p.GetPod(ctx, "default", "web"); got.Status.PodIP != "" || got.Status.ContainerStatuses[0].State.Waiting.Reason != reasonContainerCreating {
+		t.Fatalf("expected the pod to wait for its claim to be bound, got %+v", got.Status)
+	}
+	bound := claim.DeepCopy()
+	bound.Spec.VolumeName, bound.Status.Phase = "pv-data", v1.ClaimBound
+	if err := claims.Update(bound); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "web" && pod.Status.Phase == v1.PodRunning })
+
+	// The pod controller holds back pods whose environment cannot be resolved, which are
+	// taken over so their containers wait in CreateContainerConfigError.
+	held := newTestPod("held", "app")
+	held.Status.Phase = v1.PodPending
+	held.Spec.Containers[0].Env = []v1.EnvVar{{Name: "MODE", ValueFrom: &v1.EnvVarSource{
+		ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "settings"}, Key: "mode"},
+	}}}
+	if err := pods.Add(held); err != nil {
+		t.Fatal(err)
+	}
+	waiting := waitForPod(t, ch, func(pod *v1.Pod) bool {
+		return pod.Name == "held" && pod.Status.ContainerStatuses[0].State.Waiting != nil &&
+			pod.Status.ContainerStatuses[0].State.Waiting.Reason == reasonCreateContainerConfigError
+	})
+	if message := waiting.Status.ContainerStatuses[0].State.Waiting.Message; message != "couldn't find key mode in ConfigMap default/settings" {
+		t.Fatalf("unexpected message %q", message)
+	}
+	waitForEvent(t, recorder, "Warning Failed Error: couldn't find key mode")
+	withMode := settings.DeepCopy()
+	withMode.Data["mode"] = "fast"
+	if err := configMaps.Update(withMode); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "held" && pod.Status.Phase == v1.PodRunning })
+	a := &fakeAttach{}
+	if err := p.RunInContainer(ctx, "default", "held", "app", []string{"env"}, a); err != nil || !strings.Contains(a.stdout.String(), "\nMODE=fast\n") {
+		t.Fatalf("expected the resolved environment, got %q and %v", a.stdout.String(), err)
+	}
+
+	// Pods the pod controller marked Failed are left alone, as it does not update their
+	// status anymore.
+	failed := newTestPod("failed", "app")
+	failed.Spec.RestartPolicy = v1.RestartPolicyNever
+	failed.Spec.Containers[0].Env = []v1.EnvVar{{Name: "LEVEL", ValueFrom: &v1.EnvVarSource{
+		ConfigMapKeyRef: &v1.ConfigMapKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "missing"}, Key: "level"},
+	}}}
+	failed.Status = v1.PodStatus{Phase: v1.PodFailed, Reason: "ProviderFailed", Message: `configmap "missing" not found`}
+	if err := pods.Add(failed); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := p.GetPod(ctx, "default", "failed"); err == nil {
+		t.Fatal("expected the failed pod not to be created")
+	}
+}
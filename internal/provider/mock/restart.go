@@ -0,0 +1,154 @@
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const reasonCrashLoopBackOff = "CrashLoopBackOff"
+
+// Kubelet container restart back-off. These are variables so tests can shorten them.
+var (
+	backOffInitial = 10 * time.Second
+	backOffMax     = 5 * time.Minute
+)
+
+// CrashConfig makes containers exit with an error while they are running.
+type CrashConfig struct {
+	// Probability is the chance, between 0 and 1, that a run of a container crashes.
+	// If only After is set, every run crashes.
+	Probability float64 `yaml:"probability,omitempty"`
+	// After is how long a container runs before crashing.
+	After Distribution `yaml:"after,omitempty"`
+	// ExitCode is the exit code of crashed containers. Defaults to 1.
+	ExitCode int32 `yaml:"exitCode,omitempty"`
+	// Reason is the termination reason of crashed containers. Defaults to Error.
+	Reason string `yaml:"reason,omitempty"`
+}
+
+func (c CrashConfig) validate() error {
+	if c.Probability < 0 || c.Probability > 1 {
+		return fmt.Errorf("crash probability %v is not between 0 and 1", c.Probability)
+	}
+	if err := c.After.validate(); err != nil {
+		return fmt.Errorf("invalid crash.after: %v", err)
+	}
+	return nil
+}
+
+// plan decides whether a new run of a container crashes, and if so after how long.
+func (c CrashConfig) plan() (bool, time.Duration) {
+	switch {
+	case c.Probability > 0:
+		if rand.Float64() >= c.Probability {
+			return false, 0
+		}
+	case c.After.IsZero():
+		return false, 0
+	}
+	return true, c.After.Sample()
+}
+
+func (c CrashConfig) exitCode() int32 {
+	if c.ExitCode == 0 {
+		return 1
+	}
+	return c.ExitCode
+}
+
+func (c CrashConfig) reason() string {
+	if c.Reason == "" {
+		return reasonError
+	}
+	return c.Reason
+}
+
+// backOff tracks the restart back-off of a container the way the kubelet does: the first
+// restart is immediate, the following ones wait 10s, 20s, 40s... up to 5m. The back-off is
+// forgotten once the container has not exited for twice the maximum delay.
+type backOff struct {
+	delay time.Duration
+	last  time.Time
+}
+
+// next returns how long to wait before restarting a container that exited at now.
+func (b *backOff) next(now time.Time) time.Duration {
+	if b.last.IsZero() || now.Sub(b.last) > 2*backOffMax {
+		b.delay = 0
+	}
+	delay := b.delay
+	switch {
+	case b.delay == 0:
+		b.delay = backOffInitial
+	case b.delay*2 > backOffMax:
+		b.delay = backOffMax
+	default:
+		b.delay *= 2
+	}
+	b.last = now
+	return delay
+}
+
+func (mp *mockPod) backOff(container string) *backOff {
+	b, ok := mp.backOffs[container]
+	if !ok {
+		b = &backOff{}
+		mp.backOffs[container] = b
+	}
+	return b
+}
+
+// shouldRestart reports whether the kubelet restarts a container that exited with exitCode.
+// Init containers are never restarted once they have succeeded.
+func shouldRestart(policy v1.RestartPolicy, ref containerRef, exitCode int32) bool {
+	switch {
+	case policy == v1.RestartPolicyNever:
+		return false
+	case ref.init || policy == v1.RestartPolicyOnFailure:
+		return exitCode != 0
+	default:
+		return true
+	}
+}
+
+// exitContainer terminates the running container with the given exit code. Depending on
+// the pod's restart policy the container is then restarted, after waiting in
+// CrashLoopBackOff if it has been exiting repeatedly. An init container that succeeds
+// lets the next one start.
+func (p *MockProvider) exitContainer(mp *mockPod, ref containerRef, exitCode int32, reason, message string, now metav1.Time) {
+	pod := mp.pod
+	cs := ref.status(pod)
+	terminateContainer(cs, exitCode, reason, message, now)
+
+	if !shouldRestart(pod.Spec.RestartPolicy, ref, exitCode) {
+		if ref.init && exitCode == 0 {
+			cs.Ready = true
+			p.runInitContainer(mp, ref.index+1, now)
+		}
+		syncPodStatus(pod, now)
+		return
+	}
+
+	delay := mp.backOff(cs.Name).next(now.Time)
+	cs.LastTerminationState = cs.State
+	if delay > 0 {
+		cs.State = v1.ContainerState{
+			Waiting: &v1.ContainerStateWaiting{
+				Reason:  reasonCrashLoopBackOff,
+				Message: fmt.Sprintf("back-off %s restarting failed container=%s pod=%s_%s(%s)", delay, cs.Name, pod.Name, pod.Namespace, pod.UID),
+			},
+		}
+	}
+	syncPodStatus(pod, now)
+
+	p.after(mp, delay, func(now metav1.Time) bool {
+		cs := ref.status(pod)
+		cs.RestartCount++
+		p.startContainer(mp, ref, now)
+		return true
+	})
+}
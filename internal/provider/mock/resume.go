@@ -0,0 +1,106 @@
+package mock
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// restorePod makes the provider take back a pod it was running before it restarted, with
+// the status it had then and the IP it was given. Its lifecycle is left to resumePod.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) restorePod(key string, pod *v1.Pod, behavior Behavior, podIP string) *mockPod {
+	mp := newMockPod(pod, behavior)
+	p.pods[key] = mp
+	if isActive(pod) && podIP != "" && !p.restorePodIP(mp, podIP) {
+		// Without an IP left the pod waits for one, like a new pod would.
+		pod.Status.PodIP = ""
+	}
+	p.publishPod(mp)
+	return mp
+}
+
+// resumeRestoredPods resumes the lifecycle of the pods restored from the state file. Their
+// behavior is only resolved then, as the profiles select pods by the labels of their
+// namespace, and the references they mount are checked against the caches.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) resumeRestoredPods(now metav1.Time) {
+	for _, mp := range p.restored {
+		// The configuration may have changed since the pod was created.
+		if behavior, err := p.behaviorFor(mp.pod); err == nil {
+			mp.behavior = behavior
+		}
+		p.resumePod(mp, now)
+		p.publishPod(mp)
+	}
+	p.restored = nil
+}
+
+// resumePod picks up the simulated lifecycle of a restored pod, the way a restarted
+// kubelet finds the containers of its pods still running: running containers carry on
+// with their run, keeping their ID and start time, and the transitions the pod was
+// waiting on are scheduled again.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) resumePod(mp *mockPod, now metav1.Time) {
+	pod := mp.pod
+	if !isActive(pod) {
+		return
+	}
+	if mp.podIP == "" {
+		// The pod sandbox is created anew, but containers that were already started
+		// keep their status.
+		var resume func(now metav1.Time) bool
+		resume = func(now metav1.Time) bool {
+			if p.mountVolumes(mp, now, resume) && p.assignPodIP(mp, now) {
+				p.resumeContainers(mp, now)
+			}
+			return true
+		}
+		p.after(mp, mp.behavior.Startup.Started.Sample(), resume)
+		return
+	}
+	p.resumeContainers(mp, now)
+}
+
+// resumeContainers resumes the first init container that has not completed, or the app
+// containers once every init container has.
+func (p *MockProvider) resumeContainers(mp *mockPod, now metav1.Time) {
+	pod := mp.pod
+	for i := range pod.Status.InitContainerStatuses {
+		if t := pod.Status.InitContainerStatuses[i].State.Terminated; t != nil && t.ExitCode == 0 {
+			continue
+		}
+		p.resumeContainer(mp, containerRef{init: true, index: i}, now)
+		return
+	}
+	for i := range pod.Status.ContainerStatuses {
+		p.resumeContainer(mp, containerRef{index: i}, now)
+	}
+	syncPodStatus(pod, now)
+}
+
+// resumeContainer carries on the run of a running container, restarts an exited one if
+// its pod's restart policy says so, and starts a waiting one.
+func (p *MockProvider) resumeContainer(mp *mockPod, ref containerRef, now metav1.Time) {
+	pod := mp.pod
+	cs := ref.status(pod)
+	switch {
+	case cs.State.Running != nil:
+		p.scheduleRun(mp, ref, now.Sub(cs.State.Running.StartedAt.Time), now)
+	case cs.State.Terminated != nil:
+		if !shouldRestart(pod.Spec.RestartPolicy, ref, cs.State.Terminated.ExitCode) {
+			return
+		}
+		cs.LastTerminationState = cs.State
+		cs.RestartCount++
+		p.startContainer(mp, ref, now)
+	default:
+		// A container waiting in CrashLoopBackOff is restarted right away.
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == reasonCrashLoopBackOff {
+			cs.RestartCount++
+		}
+		p.startContainer(mp, ref, now)
+	}
+}
@@ -0,0 +1,162 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// defaultStateInterval is how often the provider state is saved.
+const defaultStateInterval = 30 * time.Second
+
+// StateConfig makes the provider state survive restarts of mocklet.
+type StateConfig struct {
+	// Path is the file the provider state is saved to, and restored from when mocklet
+	// starts. When unset, the state only lives in memory.
+	Path string `yaml:"path,omitempty"`
+	// Interval is how often the state is saved, besides when mocklet shuts down.
+	// Defaults to 30s.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+func (c StateConfig) validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid state.interval: negative duration")
+	}
+	return nil
+}
+
+// providerState is what is saved of the provider: the pods with their status, which holds
+// their start times, container IDs and restart counts, their IPs, and the images pulled
+// onto the node.
+type providerState struct {
+	Pods   []podState `json:"pods"`
+	Images []string   `json:"images,omitempty"`
+}
+
+type podState struct {
+	Pod   *v1.Pod `json:"pod"`
+	PodIP string  `json:"podIP,omitempty"`
+}
+
+// snapshotState returns a copy of the provider state.
+//
+// The caller must hold p.mu.
+func (p *MockProvider) snapshotState() providerState {
+	var state providerState
+	for _, mp := range p.pods {
+		state.Pods = append(state.Pods, podState{
+			Pod:   mp.pod.DeepCopy(),
+			PodIP: mp.podIP,
+		})
+	}
+	sort.Slice(state.Pods, func(i, j int) bool {
+		a, b := state.Pods[i].Pod, state.Pods[j].Pod
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	for image := range p.images {
+		state.Images = append(state.Images, image)
+	}
+	sort.Strings(state.Images)
+	return state
+}
+
+// saveState writes the provider state to its file. The file is replaced at once, so a
+// crash while saving leaves the previous state in place.
+func (p *MockProvider) saveState() error {
+	path := p.config.State.Path
+	if path == "" {
+		return nil
+	}
+	p.mu.Lock()
+	state := p.snapshotState()
+	p.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadState restores the provider state saved in its file, if there is one. Restored pods
+// keep their status and IPs, and their lifecycle resumes where it was once AdoptPods is
+// called, since the profiles and references of the pods are only known then.
+func (p *MockProvider) loadState() error {
+	path := p.config.State.Path
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state providerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, image := range state.Images {
+		p.images[image] = struct{}{}
+	}
+	for _, ps := range state.Pods {
+		key, err := buildKey(ps.Pod)
+		if err != nil {
+			return fmt.Errorf("invalid state file %s: %v", path, err)
+		}
+		// The behavior of the pod is resolved when it resumes.
+		mp := p.restorePod(key, ps.Pod, p.config.Behavior, ps.PodIP)
+		p.restored = append(p.restored, mp)
+	}
+	return nil
+}
+
+// runStateSaver saves the provider state every interval until ctx is done.
+func (p *MockProvider) runStateSaver(ctx context.Context) {
+	interval := p.config.State.Interval
+	if interval == 0 {
+		interval = defaultStateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.saveState(); err != nil {
+				log.G(ctx).WithError(err).Error("Error saving the provider state")
+			}
+		}
+	}
+}
+
+// Close saves the provider state, if it is persisted, when mocklet shuts down.
+func (p *MockProvider) Close() error {
+	return p.saveState()
+}
@@ -0,0 +1,147 @@
+package mock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VineethReddy02/mocklet/manager"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestStatePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := MockConfig{State: StateConfig{Path: filepath.Join(dir, "state.json")}}
+
+	p, ch := newTestProvider(t, config)
+	web := newTestPod("web", "app", "sidecar")
+	batch := newTestPod("batch", "job")
+	batch.Annotations = map[string]string{annotationRunDuration: "500ms"}
+	batch.Spec.RestartPolicy = v1.RestartPolicyNever
+	for _, pod := range []*v1.Pod{web, batch} {
+		if err := p.CreatePod(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+		name := pod.Name
+		waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == name && pod.Status.Phase == v1.PodRunning })
+	}
+	before, err := p.GetPod(context.Background(), "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopPods(p)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p, ch = newTestProvider(t, config)
+	defer stopPods(p)
+	after, err := p.GetPod(context.Background(), "default", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Status.Phase != v1.PodRunning || after.Status.PodIP != before.Status.PodIP {
+		t.Fatalf("expected the pod to keep running with IP %s, got %s with IP %s", before.Status.PodIP, after.Status.Phase, after.Status.PodIP)
+	}
+	for i, cs := range after.Status.ContainerStatuses {
+		old := before.Status.ContainerStatuses[i]
+		if cs.ContainerID != old.ContainerID || cs.RestartCount != old.RestartCount || cs.State.Running == nil ||
+			cs.State.Running.StartedAt.Unix() != old.State.Running.StartedAt.Unix() {
+			t.Fatalf("expected container %s to carry on with its run, got %+v", cs.Name, cs)
+		}
+	}
+
+	// The job carries on with its run and completes.
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "batch" && pod.Status.Phase == v1.PodSucceeded })
+
+	// Restored IPs are not handed out again.
+	if err := p.CreatePod(context.Background(), newTestPod("other", "app")); err != nil {
+		t.Fatal(err)
+	}
+	other := waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "other" && pod.Status.PodIP != "" })
+	if other.Status.PodIP == before.Status.PodIP {
+		t.Fatalf("expected a new IP, got the restored %s", other.Status.PodIP)
+	}
+}
+
+// TestStateRestoredWithDependencies checks that the pods restored by NewMockProvider only
+// resume once the caches are synced, so they get the profile of their namespace.
+func TestStateRestoredWithDependencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	state := filepath.Join(dir, "state.json")
+	configPath := writeFixture(t, dir, "config.yaml", `
+mocklet:
+  logs:
+    rate: 5
+  state:
+    path: `+state+`
+  profiles:
+  - name: quiet
+    namespaceSelector: team=payments
+    logs:
+      rate: 0
+`)
+	config, err := loadConfig(configPath, "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ch := newTestProvider(t, config)
+	pod := newTestPod("web", "app")
+	pod.Namespace = "checkout"
+	if err := p.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, isReady)
+	stopPods(p)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The caches are empty when the provider is created.
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	namespaces := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	rm, err := manager.NewResourceManager(corev1listers.NewPodLister(pods), nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm.SetNamespaceLister(corev1listers.NewNamespaceLister(namespaces))
+	p, err = NewMockProvider(configPath, "mocklet", "Linux", "10.0.0.1", 10250, "cluster.local", rm, record.NewFakeRecorder(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopPods(p)
+	restored, err := p.GetPod(context.Background(), "checkout", "web")
+	if err != nil || !isReady(restored) {
+		t.Fatalf("expected the restored pod to be known and ready, got %v", err)
+	}
+
+	if err := namespaces.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "checkout", Labels: map[string]string{"team": "payments"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AdoptPods(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mp := p.pods["checkout-web"]
+	if mp == nil || mp.behavior.Logs.Rate != 0 {
+		t.Fatalf("expected the restored pod to get the profile of its namespace, got %+v", mp)
+	}
+	if len(p.restored) != 0 {
+		t.Fatal("expected the restored pods to be resumed once")
+	}
+}
@@ -0,0 +1,213 @@
+package mock
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons reported in container and pod statuses, mirroring the ones used by the kubelet.
+const (
+	reasonContainerCreating        = "ContainerCreating"
+	reasonPodInitializing          = "PodInitializing"
+	reasonContainersNotReady       = "ContainersNotReady"
+	reasonContainersNotInitialized = "ContainersNotInitialized"
+	reasonPodCompleted             = "PodCompleted"
+	reasonCompleted                = "Completed"
+	reasonError                    = "Error"
+)
+
+// setPodCondition sets the given condition on the pod status. LastTransitionTime is only
+// moved forward when the condition's status actually changes.
+func setPodCondition(status *v1.PodStatus, conditionType v1.PodConditionType, conditionStatus v1.ConditionStatus, reason, message string, now metav1.Time) bool {
+	for i := range status.Conditions {
+		c := &status.Conditions[i]
+		if c.Type != conditionType {
+			continue
+		}
+		if c.Status == conditionStatus && c.Reason == reason && c.Message == message {
+			return false
+		}
+		if c.Status != conditionStatus {
+			c.LastTransitionTime = now
+		}
+		c.Status = conditionStatus
+		c.Reason = reason
+		c.Message = message
+		return true
+	}
+	status.Conditions = append(status.Conditions, v1.PodCondition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: now,
+	})
+	return true
+}
+
+// getPodCondition returns the condition of the given type, or nil if it is not set.
+func getPodCondition(status *v1.PodStatus, conditionType v1.PodConditionType) *v1.PodCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// syncPodStatus recomputes the pod phase and conditions from the container statuses.
+func syncPodStatus(pod *v1.Pod, now metav1.Time) {
+	status := &pod.Status
+	status.Phase = getPhase(&pod.Spec, status)
+	updateInitialized(status, now)
+	updateReadiness(status, now)
+}
+
+// updateInitialized recomputes the Initialized condition from the init container
+// statuses, using the same reasons and messages as the kubelet.
+func updateInitialized(status *v1.PodStatus, now metav1.Time) {
+	if status.Phase == v1.PodSucceeded {
+		setPodCondition(status, v1.PodInitialized, v1.ConditionTrue, reasonPodCompleted, "", now)
+		return
+	}
+	var incomplete []string
+	for _, cs := range status.InitContainerStatuses {
+		if t := cs.State.Terminated; t == nil || t.ExitCode != 0 {
+			incomplete = append(incomplete, cs.Name)
+		}
+	}
+	if len(incomplete) > 0 {
+		message := fmt.Sprintf("containers with incomplete status: [%s]", strings.Join(incomplete, " "))
+		setPodCondition(status, v1.PodInitialized, v1.ConditionFalse, reasonContainersNotInitialized, message, now)
+		return
+	}
+	setPodCondition(status, v1.PodInitialized, v1.ConditionTrue, "", "", now)
+}
+
+// updateReadiness recomputes the ContainersReady and Ready conditions from the
+// container statuses, using the same reasons and messages as the kubelet.
+func updateReadiness(status *v1.PodStatus, now metav1.Time) {
+	var unready []string
+	for _, cs := range status.ContainerStatuses {
+		if !cs.Ready {
+			unready = append(unready, cs.Name)
+		}
+	}
+	if status.Phase == v1.PodSucceeded || status.Phase == v1.PodFailed {
+		setPodCondition(status, v1.ContainersReady, v1.ConditionFalse, reasonPodCompleted, "", now)
+		setPodCondition(status, v1.PodReady, v1.ConditionFalse, reasonPodCompleted, "", now)
+		return
+	}
+	if len(unready) > 0 {
+		message := fmt.Sprintf("containers with unready status: [%s]", strings.Join(unready, " "))
+		setPodCondition(status, v1.ContainersReady, v1.ConditionFalse, reasonContainersNotReady, message, now)
+		setPodCondition(status, v1.PodReady, v1.ConditionFalse, reasonContainersNotReady, message, now)
+		return
+	}
+	setPodCondition(status, v1.ContainersReady, v1.ConditionTrue, "", "", now)
+	setPodCondition(status, v1.PodReady, v1.ConditionTrue, "", "", now)
+}
+
+// getPhase computes the pod phase from its container statuses the way the kubelet does.
+// Containers that are waiting to be restarted count as stopped rather than waiting.
+func getPhase(spec *v1.PodSpec, status *v1.PodStatus) v1.PodPhase {
+	var pendingInitialization, failedInitialization int
+	for _, container := range spec.InitContainers {
+		cs := findContainerStatus(status.InitContainerStatuses, container.Name)
+		if cs == nil {
+			pendingInitialization++
+			continue
+		}
+		switch {
+		case cs.State.Terminated != nil:
+			if cs.State.Terminated.ExitCode != 0 {
+				failedInitialization++
+			}
+		case cs.State.Waiting != nil && cs.LastTerminationState.Terminated != nil:
+			if cs.LastTerminationState.Terminated.ExitCode != 0 {
+				failedInitialization++
+			}
+		default:
+			pendingInitialization++
+		}
+	}
+
+	var running, waiting, stopped, succeeded, unknown int
+	for _, container := range spec.Containers {
+		cs := findContainerStatus(status.ContainerStatuses, container.Name)
+		if cs == nil {
+			unknown++
+			continue
+		}
+		switch {
+		case cs.State.Running != nil:
+			running++
+		case cs.State.Terminated != nil:
+			stopped++
+			if cs.State.Terminated.ExitCode == 0 {
+				succeeded++
+			}
+		case cs.State.Waiting != nil:
+			if cs.LastTerminationState.Terminated != nil {
+				stopped++
+			} else {
+				waiting++
+			}
+		default:
+			unknown++
+		}
+	}
+
+	if failedInitialization > 0 && spec.RestartPolicy == v1.RestartPolicyNever {
+		return v1.PodFailed
+	}
+
+	switch {
+	case pendingInitialization > 0 || waiting > 0:
+		return v1.PodPending
+	case running > 0 && unknown == 0:
+		return v1.PodRunning
+	case running == 0 && stopped > 0 && unknown == 0:
+		if spec.RestartPolicy == v1.RestartPolicyAlways {
+			return v1.PodRunning
+		}
+		if stopped == succeeded {
+			return v1.PodSucceeded
+		}
+		if spec.RestartPolicy == v1.RestartPolicyNever {
+			return v1.PodFailed
+		}
+		return v1.PodRunning
+	default:
+		return v1.PodPending
+	}
+}
+
+// findContainerStatus returns the status of the named container, or nil if there is none.
+func findContainerStatus(statuses []v1.ContainerStatus, name string) *v1.ContainerStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// terminateContainer moves the container into the terminated state.
+func terminateContainer(cs *v1.ContainerStatus, exitCode int32, reason, message string, now metav1.Time) {
+	terminated := &v1.ContainerStateTerminated{
+		ExitCode:    exitCode,
+		Reason:      reason,
+		Message:     message,
+		FinishedAt:  now,
+		ContainerID: cs.ContainerID,
+	}
+	if cs.State.Running != nil {
+		terminated.StartedAt = cs.State.Running.StartedAt
+	}
+	cs.Ready = false
+	cs.State = v1.ContainerState{Terminated: terminated}
+}
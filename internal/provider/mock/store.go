@@ -0,0 +1,139 @@
+package mock
+
+import (
+	"hash/fnv"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// podStoreShards is the number of shards of the pod store. Each shard has its own lock,
+// so readers of different pods rarely wait on each other.
+const podStoreShards = 64
+
+// podStore holds snapshots of the pods for the pod controller and the HTTP servers to read
+// without waiting on the lifecycle transitions. Snapshots are never modified once stored,
+// and readers get copies of them, as the pod controller expects to own the pods it is
+// given.
+//
+// Pods are indexed by namespace, then by name, so that namespace-scoped reads only go
+// through the pods of that namespace.
+type podStore struct {
+	shards [podStoreShards]podStoreShard
+}
+
+type podStoreShard struct {
+	mu          sync.RWMutex
+	byNamespace map[string]map[string]*v1.Pod
+}
+
+func newPodStore() *podStore {
+	s := &podStore{}
+	for i := range s.shards {
+		s.shards[i].byNamespace = make(map[string]map[string]*v1.Pod)
+	}
+	return s
+}
+
+// shard returns the shard of the pod. Pods are spread over the shards by namespace and
+// name, so that a namespace with many pods does not end up behind a single lock.
+func (s *podStore) shard(namespace, name string) *podStoreShard {
+	h := fnv.New32a()
+	h.Write([]byte(namespace)) //nolint:errcheck
+	h.Write([]byte{'/'})       //nolint:errcheck
+	h.Write([]byte(name))      //nolint:errcheck
+	return &s.shards[h.Sum32()%podStoreShards]
+}
+
+// put stores a snapshot of the pod.
+func (s *podStore) put(pod *v1.Pod) {
+	snapshot := pod.DeepCopy()
+	shard := s.shard(pod.Namespace, pod.Name)
+	shard.mu.Lock()
+	pods := shard.byNamespace[pod.Namespace]
+	if pods == nil {
+		pods = make(map[string]*v1.Pod)
+		shard.byNamespace[pod.Namespace] = pods
+	}
+	pods[pod.Name] = snapshot
+	shard.mu.Unlock()
+}
+
+// delete removes the pod, if it is stored.
+func (s *podStore) delete(namespace, name string) {
+	shard := s.shard(namespace, name)
+	shard.mu.Lock()
+	if pods := shard.byNamespace[namespace]; pods != nil {
+		delete(pods, name)
+		if len(pods) == 0 {
+			delete(shard.byNamespace, namespace)
+		}
+	}
+	shard.mu.Unlock()
+}
+
+// get returns a copy of the pod, or nil if it is not stored.
+func (s *podStore) get(namespace, name string) *v1.Pod {
+	shard := s.shard(namespace, name)
+	shard.mu.RLock()
+	pod := shard.byNamespace[namespace][name]
+	shard.mu.RUnlock()
+	if pod == nil {
+		return nil
+	}
+	return pod.DeepCopy()
+}
+
+// list returns copies of every pod.
+func (s *podStore) list() []*v1.Pod {
+	return s.collect(func(shard *podStoreShard, snapshots []*v1.Pod) []*v1.Pod {
+		for _, pods := range shard.byNamespace {
+			for _, pod := range pods {
+				snapshots = append(snapshots, pod)
+			}
+		}
+		return snapshots
+	})
+}
+
+// listNamespace returns copies of the pods in the namespace.
+func (s *podStore) listNamespace(namespace string) []*v1.Pod {
+	return s.collect(func(shard *podStoreShard, snapshots []*v1.Pod) []*v1.Pod {
+		for _, pod := range shard.byNamespace[namespace] {
+			snapshots = append(snapshots, pod)
+		}
+		return snapshots
+	})
+}
+
+// collect appends the snapshots picked from each shard, and returns copies of them. Shards
+// are locked one at a time, and the copies are made once the lock is released since
+// snapshots do not change.
+func (s *podStore) collect(pick func(*podStoreShard, []*v1.Pod) []*v1.Pod) []*v1.Pod {
+	var snapshots []*v1.Pod
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		snapshots = pick(shard, snapshots)
+		shard.mu.RUnlock()
+	}
+	pods := make([]*v1.Pod, len(snapshots))
+	for i, pod := range snapshots {
+		pods[i] = pod.DeepCopy()
+	}
+	return pods
+}
+
+// len returns the number of pods stored.
+func (s *podStore) len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.RLock()
+		for _, pods := range shard.byNamespace {
+			n += len(pods)
+		}
+		shard.mu.RUnlock()
+	}
+	return n
+}
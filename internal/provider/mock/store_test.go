@@ -0,0 +1,175 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPodStore(t *testing.T) {
+	s := newPodStore()
+	for i := 0; i < 100; i++ {
+		pod := newTestPod(fmt.Sprintf("pod-%d", i), "app")
+		if i%2 == 1 {
+			pod.Namespace = "kube-system"
+		}
+		s.put(pod)
+	}
+	if n := s.len(); n != 100 {
+		t.Fatalf("expected 100 pods, got %d", n)
+	}
+	if pods := s.listNamespace("kube-system"); len(pods) != 50 {
+		t.Fatalf("expected 50 pods in kube-system, got %d", len(pods))
+	}
+	if pods := s.listNamespace("other"); len(pods) != 0 {
+		t.Fatalf("expected no pods in other, got %d", len(pods))
+	}
+
+	// Readers get copies, which they can modify.
+	pod := s.get("default", "pod-0")
+	pod.Status.Phase = v1.PodRunning
+	if s.get("default", "pod-0").Status.Phase != "" {
+		t.Fatal("expected the stored pod to be left alone")
+	}
+	s.put(pod)
+	if s.get("default", "pod-0").Status.Phase != v1.PodRunning || len(s.listNamespace("default")) != 50 {
+		t.Fatal("expected the pod to be replaced")
+	}
+
+	// Names are only unique within a namespace.
+	if s.get("kube-system", "pod-0") != nil {
+		t.Fatal("expected no pod-0 in kube-system")
+	}
+
+	s.delete("default", "pod-0")
+	s.delete("default", "pod-0")
+	if s.get("default", "pod-0") != nil || len(s.listNamespace("default")) != 49 || s.len() != 99 {
+		t.Fatal("expected the pod to be deleted")
+	}
+}
+
+// TestConcurrentPodOperations runs the provider the way the pod controller does, with
+// several workers and the HTTP servers calling it at once. Run it with -race.
+func TestConcurrentPodOperations(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Pods: "1000"})
+	defer stopPods(p)
+	go func() {
+		for range ch {
+		}
+	}()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				pod := newTestPod(fmt.Sprintf("pod-%d-%d", w, i), "app")
+				// The provider keeps the pod it is given, like the pod controller's copy.
+				if err := p.CreatePod(ctx, pod.DeepCopy()); err != nil {
+					t.Error(err)
+					return
+				}
+				pod.Labels = map[string]string{"updated": "true"}
+				if err := p.UpdatePod(ctx, pod); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := p.GetPod(ctx, pod.Namespace, pod.Name); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%2 == 0 {
+					if err := p.DeletePod(ctx, pod); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := p.GetPods(ctx); err != nil {
+					t.Error(err)
+				}
+				if _, err := p.GetStatsSummary(ctx); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Deleted pods are forgotten once their containers have stopped.
+	deadline := time.Now().Add(5 * time.Second)
+	for p.store.len() != 8*25 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pods that were not deleted to remain, got %d", p.store.len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newBenchmarkStore(n int) *podStore {
+	s := newPodStore()
+	for i := 0; i < n; i++ {
+		pod := newTestPod(fmt.Sprintf("pod-%d", i), "app")
+		pod.Namespace = fmt.Sprintf("ns-%d", i%100)
+		s.put(pod)
+	}
+	return s
+}
+
+func BenchmarkPodStoreGet(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.get(fmt.Sprintf("ns-%d", i%100), fmt.Sprintf("pod-%d", i%100000))
+			i++
+		}
+	})
+}
+
+func BenchmarkPodStorePut(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	pods := make([]*v1.Pod, 1000)
+	for i := range pods {
+		pods[i] = newTestPod(fmt.Sprintf("pod-%d", i), "app")
+		pods[i].Namespace = fmt.Sprintf("ns-%d", i%100)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.put(pods[i%len(pods)])
+			i++
+		}
+	})
+}
+
+func BenchmarkPodStoreList(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.list()
+	}
+}
+
+func BenchmarkPodStoreListNamespace(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.listNamespace(fmt.Sprintf("ns-%d", i%100))
+	}
+}
@@ -0,0 +1,178 @@
+package mock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultTerminationGracePeriod is the grace period of pods that do not set one.
+const defaultTerminationGracePeriod = 30 * time.Second
+
+// TerminationConfig describes how containers shut down when their pod is deleted.
+type TerminationConfig struct {
+	// PreStop is how long the preStop hook of containers that have one runs. A preStop
+	// exec hook running "sleep <seconds>" takes that long instead.
+	PreStop Distribution `yaml:"preStop,omitempty"`
+	// Shutdown is the time containers take to exit once they receive SIGTERM.
+	Shutdown Distribution `yaml:"shutdown,omitempty"`
+	// IgnoreSIGTERM makes containers run until the end of the grace period, when they
+	// are killed.
+	IgnoreSIGTERM bool `yaml:"ignoreSIGTERM,omitempty"`
+}
+
+func (c TerminationConfig) validate() error {
+	if err := c.PreStop.validate(); err != nil {
+		return fmt.Errorf("invalid termination.preStop: %v", err)
+	}
+	if err := c.Shutdown.validate(); err != nil {
+		return fmt.Errorf("invalid termination.shutdown: %v", err)
+	}
+	return nil
+}
+
+// stopTime returns how long the container takes to stop gracefully: its preStop hook
+// runs first, then it is sent SIGTERM.
+func (c TerminationConfig) stopTime(container *v1.Container) time.Duration {
+	d := c.Shutdown.Sample()
+	if container.Lifecycle != nil && container.Lifecycle.PreStop != nil {
+		if sleep, ok := preStopSleep(container.Lifecycle.PreStop); ok {
+			d += sleep
+		} else {
+			d += c.PreStop.Sample()
+		}
+	}
+	return d
+}
+
+// preStopSleep returns the duration of a preStop exec hook that sleeps, such as
+// ["sleep", "15"] or ["/bin/sh", "-c", "sleep 15"].
+func preStopSleep(hook *v1.Handler) (time.Duration, bool) {
+	if hook.Exec == nil {
+		return 0, false
+	}
+	var words []string
+	for _, arg := range hook.Exec.Command {
+		words = append(words, strings.Fields(arg)...)
+	}
+	for i := 0; i+1 < len(words); i++ {
+		if words[i] != "sleep" && !strings.HasSuffix(words[i], "/sleep") {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(words[i+1], 64)
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// gracePeriod returns the grace period of the pod's deletion.
+func gracePeriod(pod *v1.Pod) time.Duration {
+	switch {
+	case pod.DeletionGracePeriodSeconds != nil:
+		return time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second
+	case pod.Spec.TerminationGracePeriodSeconds != nil:
+		return time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+	default:
+		return defaultTerminationGracePeriod
+	}
+}
+
+// terminatePod shuts the pod down the way the kubelet does once it is deleted. The
+// pending lifecycle transitions are cancelled, and running containers keep running until
+// they stop gracefully or are killed at the end of the grace period. Containers that are
+// not running are terminated right away. Once every container has terminated the pod is
+// forgotten.
+//
+// A later deletion with a shorter grace period, like a forced one, kills the containers
+// sooner.
+func (p *MockProvider) terminatePod(mp *mockPod, key string, grace time.Duration, now metav1.Time) {
+	pod := mp.pod
+	deadline := now.Add(grace)
+	if mp.terminating {
+		if deadline.Before(mp.deadline) {
+			mp.deadline = deadline
+			mp.forEachContainer(func(ref containerRef) {
+				p.killContainer(mp, key, ref, grace)
+			})
+		}
+		return
+	}
+	mp.cancel()
+	mp.terminating = true
+	mp.deadline = deadline
+
+	term := mp.behavior.Termination
+	mp.forEachContainer(func(ref containerRef) {
+		cs := ref.status(pod)
+		if cs.State.Running == nil {
+			if cs.State.Terminated == nil {
+				terminateContainer(cs, 0, "MockProviderPodContainerDeleted", "Mock provider terminated container upon deletion", now)
+			}
+			return
+		}
+		if !term.IgnoreSIGTERM {
+			if d := term.stopTime(ref.container(pod)); d < grace {
+				p.afterRun(mp, ref, d, func(cs *v1.ContainerStatus, now metav1.Time) bool {
+					terminateContainer(cs, 0, reasonCompleted, "", now)
+					p.finishTermination(mp, key, now)
+					return true
+				})
+				return
+			}
+		}
+		p.killContainer(mp, key, ref, grace)
+	})
+	p.finishTermination(mp, key, now)
+}
+
+// killContainer kills the running container after d.
+func (p *MockProvider) killContainer(mp *mockPod, key string, ref containerRef, d time.Duration) {
+	p.afterRun(mp, ref, d, func(cs *v1.ContainerStatus, now metav1.Time) bool {
+		terminateContainer(cs, exitCodeKilled, reasonError, "", now)
+		p.finishTermination(mp, key, now)
+		return true
+	})
+}
+
+// finishTermination reports the pod as terminated and forgets it once none of its
+// containers is running anymore.
+func (p *MockProvider) finishTermination(mp *mockPod, key string, now metav1.Time) {
+	running := false
+	mp.forEachContainer(func(ref containerRef) {
+		running = running || ref.status(mp.pod).State.Running != nil
+	})
+	if running {
+		syncPodStatus(mp.pod, now)
+		return
+	}
+
+	// Pods that were rejected on admission keep their reason.
+	status := &mp.pod.Status
+	if status.Phase != v1.PodFailed || status.Reason == "" {
+		status.Phase = v1.PodSucceeded
+		status.Reason = "MockProviderPodDeleted"
+	}
+	updateReadiness(status, now)
+	if p.pods[key] == mp {
+		delete(p.pods, key)
+		p.store.delete(mp.pod.Namespace, mp.pod.Name)
+	}
+	p.releasePodIP(mp)
+	mp.stop()
+}
+
+func (mp *mockPod) forEachContainer(fn func(ref containerRef)) {
+	for i := range mp.pod.Status.InitContainerStatuses {
+		fn(containerRef{init: true, index: i})
+	}
+	for i := range mp.pod.Status.ContainerStatuses {
+		fn(containerRef{index: i})
+	}
+}
@@ -0,0 +1,205 @@
+package mock
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// UsageConfig sets the resource usage reported for containers in the stats summary.
+type UsageConfig struct {
+	// CPU is the CPU used by each container, e.g. "250m". When unset, the usage of each
+	// container walks at random between its request and its limit.
+	CPU string `yaml:"cpu,omitempty"`
+	// Memory is the memory used by each container, e.g. "2Gi". When unset, the usage of
+	// each container walks at random between its request and its limit.
+	Memory string `yaml:"memory,omitempty"`
+	// Disk is the space each container uses on the node filesystem, e.g. "1Gi".
+	Disk string `yaml:"disk,omitempty"`
+	// Processes is the number of processes run by each container. Defaults to 1.
+	Processes uint64 `yaml:"processes,omitempty"`
+	// CPUModel and MemoryModel make the CPU and memory usage of each container change over
+	// time. They take precedence over CPU and Memory.
+	CPUModel    UsageModel `yaml:"cpuModel,omitempty"`
+	MemoryModel UsageModel `yaml:"memoryModel,omitempty"`
+	// Fixtures replay time series as the usage of the containers they select. They take
+	// precedence over the models.
+	Fixtures []UsageFixture `yaml:"fixtures,omitempty"`
+
+	cpuNanoCores *uint64
+	memoryBytes  *uint64
+	diskBytes    *uint64
+}
+
+// compile validates the configuration and parses the quantities.
+func (c *UsageConfig) compile() error {
+	var err error
+	if c.cpuNanoCores, err = parseUsage(c.CPU, resource.Nano); err != nil {
+		return fmt.Errorf("invalid CPU quantity %q", c.CPU)
+	}
+	if c.memoryBytes, err = parseUsage(c.Memory, 0); err != nil {
+		return fmt.Errorf("invalid memory quantity %q", c.Memory)
+	}
+	if c.diskBytes, err = parseUsage(c.Disk, 0); err != nil {
+		return fmt.Errorf("invalid disk quantity %q", c.Disk)
+	}
+	if err := c.CPUModel.compile(resource.Nano); err != nil {
+		return fmt.Errorf("invalid CPU model: %v", err)
+	}
+	if err := c.MemoryModel.compile(0); err != nil {
+		return fmt.Errorf("invalid memory model: %v", err)
+	}
+	// Profiles inheriting the fixtures of the node share them until they are compiled.
+	c.Fixtures = append([]UsageFixture(nil), c.Fixtures...)
+	for i := range c.Fixtures {
+		if err := c.Fixtures[i].compile(); err != nil {
+			return fmt.Errorf("invalid fixtures[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// pinCPU sets the CPU used by each container, whatever the models and fixtures say.
+func (c *UsageConfig) pinCPU(v string) error {
+	cpu, err := parseUsage(v, resource.Nano)
+	if err != nil {
+		return fmt.Errorf("invalid CPU quantity %q", v)
+	}
+	c.CPU, c.cpuNanoCores, c.CPUModel = v, cpu, UsageModel{}
+	fixtures := make([]UsageFixture, len(c.Fixtures))
+	for i, f := range c.Fixtures {
+		f.CPU, f.cpu = "", nil
+		fixtures[i] = f
+	}
+	c.Fixtures = fixtures
+	return nil
+}
+
+// parseUsage parses a quantity into a value of the given scale, or nil if s is empty.
+func parseUsage(s string, scale resource.Scale) (*uint64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return nil, err
+	}
+	if q.Sign() < 0 {
+		return nil, fmt.Errorf("negative quantity")
+	}
+	v := uint64(q.ScaledValue(scale))
+	return &v, nil
+}
+
+// containerUsage is the resource usage of a container at a point in time.
+type containerUsage struct {
+	cpuNanoCores       uint64
+	cpuCoreNanoSeconds uint64
+	memoryBytes        uint64
+	diskBytes          uint64
+	processes          uint64
+}
+
+func (u *containerUsage) add(other containerUsage) {
+	u.cpuNanoCores += other.cpuNanoCores
+	u.cpuCoreNanoSeconds += other.cpuCoreNanoSeconds
+	u.memoryBytes += other.memoryBytes
+	u.diskBytes += other.diskBytes
+	u.processes += other.processes
+}
+
+// usageState carries the usage of a container run from one sample to the next.
+type usageState struct {
+	containerID string
+	started     time.Time
+	cpuWalk     usageWalk
+	memoryWalk  usageWalk
+	cpu         cpuCounter
+	// fixture is replayed from the start of the run, if one selects the container.
+	fixture *UsageFixture
+}
+
+// sample returns the usage of a running container at now.
+func (c UsageConfig) sample(container *v1.Container, s *usageState, now time.Time, allocatable v1.ResourceList) containerUsage {
+	u := containerUsage{processes: c.Processes}
+	elapsed := now.Sub(s.started)
+	switch {
+	case s.fixture != nil && s.fixture.cpu != nil:
+		u.cpuNanoCores = uint64(s.fixture.cpu.value(elapsed))
+	case !c.CPUModel.IsZero():
+		min, max := c.CPUModel.bounds(container, v1.ResourceCPU, allocatable)
+		u.cpuNanoCores = uint64(c.CPUModel.sample(&s.cpuWalk, s.started, s.cpu.sampled, now, min, max))
+	case c.cpuNanoCores != nil:
+		u.cpuNanoCores = *c.cpuNanoCores
+	default:
+		min, max := defaultBounds(container, v1.ResourceCPU)
+		u.cpuNanoCores = uint64(defaultUsageModel.sample(&s.cpuWalk, s.started, s.cpu.sampled, now, min, max))
+	}
+	switch {
+	case s.fixture != nil && s.fixture.memory != nil:
+		u.memoryBytes = uint64(s.fixture.memory.value(elapsed))
+	case !c.MemoryModel.IsZero():
+		min, max := c.MemoryModel.bounds(container, v1.ResourceMemory, allocatable)
+		u.memoryBytes = uint64(c.MemoryModel.sample(&s.memoryWalk, s.started, s.cpu.sampled, now, min, max))
+	case c.memoryBytes != nil:
+		u.memoryBytes = *c.memoryBytes
+	default:
+		min, max := defaultBounds(container, v1.ResourceMemory)
+		u.memoryBytes = uint64(defaultUsageModel.sample(&s.memoryWalk, s.started, s.cpu.sampled, now, min, max))
+	}
+	u.cpuCoreNanoSeconds = s.cpu.add(u.cpuNanoCores, now)
+	if c.diskBytes != nil {
+		u.diskBytes = *c.diskBytes
+	}
+	if u.processes == 0 {
+		u.processes = 1
+	}
+	return u
+}
+
+// usage samples the usage of the pod's app containers at now, in the order of the pod spec.
+// Only running containers use resources. Each run of a container carries on from its last
+// sample, and the CPU time of the runs that ended is added to cpuCoreNanoSeconds.
+func (mp *mockPod) usage(now time.Time, allocatable v1.ResourceList) []containerUsage {
+	if mp.usageStates == nil {
+		mp.usageStates = make(map[string]*usageState)
+	}
+	usage := make([]containerUsage, len(mp.pod.Spec.Containers))
+	for i, cs := range mp.pod.Status.ContainerStatuses {
+		s := mp.usageStates[cs.Name]
+		if s != nil && (cs.State.Running == nil || s.containerID != cs.ContainerID) {
+			mp.cpuCoreNanoSeconds += s.cpu.coreNanoSeconds
+			delete(mp.usageStates, cs.Name)
+			s = nil
+		}
+		if cs.State.Running == nil {
+			continue
+		}
+		if s == nil {
+			started := cs.State.Running.StartedAt.Time
+			if started.IsZero() || started.After(now) {
+				started = now
+			}
+			s = &usageState{
+				containerID: cs.ContainerID,
+				started:     started,
+				cpu:         cpuCounter{sampled: started},
+				fixture:     mp.behavior.Usage.fixtureFor(mp.pod, mp.pod.Spec.Containers[i].Image),
+			}
+			mp.usageStates[cs.Name] = s
+		}
+		usage[i] = mp.behavior.Usage.sample(&mp.pod.Spec.Containers[i], s, now, allocatable)
+	}
+	return usage
+}
+
+// podUsage returns the total usage of the pod's containers.
+func podUsage(usage []containerUsage) containerUsage {
+	var total containerUsage
+	for _, u := range usage {
+		total.add(u)
+	}
+	return total
+}
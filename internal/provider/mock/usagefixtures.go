@@ -0,0 +1,299 @@
+package mock
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// UsageFixture replays time series, such as the ones exported from Prometheus, as the CPU
+// and memory usage of the containers it selects.
+type UsageFixture struct {
+	ContainerSelector `yaml:",inline"`
+	// CPU is the file of the CPU usage, in cores or as a quantity such as "250m", and Memory
+	// the file of the memory usage, in bytes or as a quantity. At least one is required.
+	// CSV files hold a time and a value per line, after an optional header. JSON files hold
+	// a list of [time, value] pairs or {"time": ..., "value": ...} objects, or the response
+	// of a Prometheus range query, whose first series is replayed. Times are RFC3339 or
+	// seconds since the epoch.
+	CPU    string `yaml:"cpu,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+	// Speed scales the time between the points: 60 replays an hour in a minute. Defaults to 1.
+	Speed float64 `yaml:"speed,omitempty"`
+	// Loop starts the series over once they end. Otherwise their last value is kept.
+	Loop bool `yaml:"loop,omitempty"`
+
+	cpu, memory *usageSeries
+}
+
+// compile validates the fixture and loads its files.
+func (f *UsageFixture) compile() error {
+	if f.CPU == "" && f.Memory == "" {
+		return fmt.Errorf("cpu or memory is required")
+	}
+	if f.Speed < 0 {
+		return fmt.Errorf("negative speed")
+	}
+	if err := f.ContainerSelector.compile(); err != nil {
+		return err
+	}
+	speed := f.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	f.cpu, f.memory = nil, nil
+	var err error
+	if f.CPU != "" {
+		if f.cpu, err = loadUsageSeries(f.CPU, resource.Nano, speed, f.Loop); err != nil {
+			return err
+		}
+	}
+	if f.Memory != "" {
+		if f.memory, err = loadUsageSeries(f.Memory, 0, speed, f.Loop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixtureFor returns the fixture replayed as the usage of the container running image, or
+// nil if there is none. The first matching fixture applies.
+func (c UsageConfig) fixtureFor(pod *v1.Pod, image string) *UsageFixture {
+	i := firstMatching(len(c.Fixtures), func(i int) *ContainerSelector { return &c.Fixtures[i].ContainerSelector }, pod, image)
+	if i < 0 {
+		return nil
+	}
+	return &c.Fixtures[i]
+}
+
+// usagePoint is a point of a time series as read from a file.
+type usagePoint struct {
+	time  time.Time
+	value float64
+}
+
+// usageSeries is a time series ready to be replayed. Its values are reached at their offset
+// from the first point and interpolated in between.
+type usageSeries struct {
+	offsets []time.Duration
+	values  []float64
+	// cycle is how long a replay of the whole series takes when it loops.
+	cycle time.Duration
+	loop  bool
+}
+
+// loadUsageSeries reads the time series at path, whose values are converted to the given
+// scale. The time between its points is divided by speed.
+func loadUsageSeries(path string, scale resource.Scale, speed float64, loop bool) (*usageSeries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var points []usagePoint
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		points, err = readCSVSeries(file, scale)
+	case ".json":
+		points, err = readJSONSeries(file, scale)
+	default:
+		return nil, fmt.Errorf("%s: unsupported format %q, expected .csv or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("%s: no points", path)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+
+	series := &usageSeries{loop: loop}
+	for _, point := range points {
+		series.offsets = append(series.offsets, time.Duration(float64(point.time.Sub(points[0].time))/speed))
+		series.values = append(series.values, point.value)
+	}
+	series.cycle = replayCycle(series.offsets, 1)
+	return series, nil
+}
+
+// readCSVSeries reads the points of a CSV file, made of a time and a value per line. The
+// first line is skipped when its time does not parse, as the header.
+func readCSVSeries(r io.Reader, scale resource.Scale) ([]usagePoint, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var points []usagePoint
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected a time and a value", n)
+		}
+		t, err := parseSeriesTime(strings.TrimSpace(record[0]))
+		if err != nil {
+			if n == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		v, err := parseSeriesValue(strings.TrimSpace(record[1]), scale)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if !math.IsNaN(v) {
+			points = append(points, usagePoint{t, v})
+		}
+	}
+}
+
+// prometheusResponse is the response of a Prometheus range query.
+type prometheusResponse struct {
+	Data *struct {
+		Result []struct {
+			Values [][2]interface{} `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// readJSONSeries reads the points of a JSON file.
+func readJSONSeries(r io.Reader, scale resource.Scale) ([]usagePoint, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var pairs [][2]interface{}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		var response prometheusResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		if response.Data == nil || len(response.Data.Result) == 0 {
+			return nil, fmt.Errorf("no series in the Prometheus response")
+		}
+		pairs = response.Data.Result[0].Values
+	} else {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		for i, item := range items {
+			var pair [2]interface{}
+			if err := json.Unmarshal(item, &pair); err != nil {
+				var object map[string]interface{}
+				if err := json.Unmarshal(item, &object); err != nil {
+					return nil, fmt.Errorf("point %d: expected a [time, value] pair or an object", i)
+				}
+				for _, key := range fixtureTimeKeys {
+					if t, ok := object[key]; ok {
+						pair[0] = t
+						break
+					}
+				}
+				pair[1] = object["value"]
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+
+	var points []usagePoint
+	for i, pair := range pairs {
+		t, err := parseSeriesTime(pair[0])
+		if err != nil {
+			return nil, fmt.Errorf("point %d: %v", i, err)
+		}
+		v, err := parseSeriesValue(pair[1], scale)
+		if err != nil {
+			return nil, fmt.Errorf("point %d: %v", i, err)
+		}
+		if !math.IsNaN(v) {
+			points = append(points, usagePoint{t, v})
+		}
+	}
+	return points, nil
+}
+
+// parseSeriesTime parses an RFC3339 time or a number of seconds since the epoch.
+func parseSeriesTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Second))), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Unix(0, int64(f*float64(time.Second))), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v", v)
+}
+
+// parseSeriesValue parses a plain number, in cores or bytes, or a quantity into a value of
+// the given scale. NaN values, which Prometheus reports for missing data, are returned as is.
+func parseSeriesValue(v interface{}, scale resource.Scale) (float64, error) {
+	var f float64
+	switch v := v.(type) {
+	case float64:
+		f = v
+	case string:
+		var err error
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			q, err := resource.ParseQuantity(v)
+			if err != nil || q.Sign() < 0 {
+				return 0, fmt.Errorf("invalid value %q", v)
+			}
+			return float64(q.ScaledValue(scale)), nil
+		}
+	default:
+		return 0, fmt.Errorf("invalid value %v", v)
+	}
+	if f < 0 || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid value %v", f)
+	}
+	return f * math.Pow10(-int(scale)), nil
+}
+
+// value returns the value of the series elapsed into its replay.
+func (s *usageSeries) value(elapsed time.Duration) float64 {
+	n := len(s.offsets)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if s.loop {
+		elapsed %= s.cycle
+	} else if elapsed >= s.offsets[n-1] {
+		return s.values[n-1]
+	}
+	// i is the first point after elapsed.
+	i := sort.Search(n, func(i int) bool { return s.offsets[i] > elapsed })
+	if i == 0 {
+		return s.values[0]
+	}
+	from, to := s.offsets[i-1], s.cycle
+	next := s.values[0]
+	if i < n {
+		to, next = s.offsets[i], s.values[i]
+	}
+	if to <= from {
+		return next
+	}
+	return s.values[i-1] + (next-s.values[i-1])*float64(elapsed-from)/float64(to-from)
+}
@@ -0,0 +1,111 @@
+package mock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestLoadUsageSeries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// CSV exports, with a header and values in cores, replayed sixty times as fast.
+	csv := writeFixture(t, dir, "cpu.csv", "Time,Value\n1600000000,0.5\n1600000060,1.5\n1600000120,NaN\n1600000180,0.5\n")
+	series, err := loadUsageSeries(csv, resource.Nano, 60, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for elapsed, want := range map[time.Duration]float64{0: 5e8, 500 * time.Millisecond: 1e9, time.Second: 1.5e9, 2 * time.Second: 1e9, time.Hour: 5e8} {
+		if v := series.value(elapsed); v != want {
+			t.Fatalf("expected %v after %v, got %v", want, elapsed, v)
+		}
+	}
+	series.loop = true
+	if v := series.value(series.cycle + time.Second); v != 1.5e9 {
+		t.Fatalf("expected the series to start over, got %v", v)
+	}
+
+	// Prometheus range queries and JSON lists of quantities.
+	prometheus := writeFixture(t, dir, "memory.json", `{"status": "success", "data": {"resultType": "matrix", "result": [
+		{"metric": {"pod": "web-0"}, "values": [[1600000000, "104857600"], [1600000015, "209715200"]]},
+		{"metric": {"pod": "web-1"}, "values": [[1600000000, "1"]]}
+	]}}`)
+	if series, err = loadUsageSeries(prometheus, 0, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(series.values) != 2 || series.value(15*time.Second) != 200<<20 {
+		t.Fatalf("expected the first series, got %+v", series)
+	}
+	list := writeFixture(t, dir, "list.json", `[{"time": "2020-09-13T12:00:00Z", "value": "100Mi"}, ["2020-09-13T12:00:10Z", "300Mi"]]`)
+	if series, err = loadUsageSeries(list, 0, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if v := series.value(5 * time.Second); v != 200<<20 {
+		t.Fatalf("expected the middle of the points, got %v", v)
+	}
+
+	for name, content := range map[string]string{
+		"empty.csv":    "Time,Value\n",
+		"negative.csv": "1600000000,-1\n",
+		"broken.json":  `[{"time": "yesterday", "value": 1}]`,
+		"series.txt":   "1600000000 1\n",
+	} {
+		if _, err := loadUsageSeries(writeFixture(t, dir, name, content), 0, 1, false); err == nil {
+			t.Fatalf("expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestUsageFixtureReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mocklet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{Usage: UsageConfig{
+		CPU: "100m",
+		Fixtures: []UsageFixture{{
+			ContainerSelector: ContainerSelector{Selector: "app=web"},
+			CPU:               writeFixture(t, dir, "cpu.csv", "1600000000,2\n1600003600,2\n"),
+			Memory:            writeFixture(t, dir, "memory.json", `[[1600000000, "1Gi"]]`),
+			Loop:              true,
+		}},
+	}}})
+	defer stopPods(p)
+	web := newTestPod("web", "app")
+	web.Labels = map[string]string{"app": "web"}
+	pinned := newTestPod("pinned", "app")
+	pinned.Labels = map[string]string{"app": "web"}
+	pinned.Annotations = map[string]string{annotationCPUUsage: "250m"}
+	for _, pod := range []*v1.Pod{web, pinned, newTestPod("other", "app")} {
+		if err := p.CreatePod(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+		name := pod.Name
+		waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == name && pod.Status.Phase == v1.PodRunning })
+	}
+
+	summary, err := p.GetStatsSummary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"web": 2e9, "pinned": 25e7, "other": 1e8}
+	for _, pod := range summary.Pods {
+		c := pod.Containers[0]
+		if *c.CPU.UsageNanoCores != want[pod.PodRef.Name] {
+			t.Fatalf("expected %s to use %d nanocores, got %d", pod.PodRef.Name, want[pod.PodRef.Name], *c.CPU.UsageNanoCores)
+		}
+		if pod.PodRef.Name != "other" && *c.Memory.UsageBytes != 1<<30 {
+			t.Fatalf("expected %s to use the memory of the fixture, got %d", pod.PodRef.Name, *c.Memory.UsageBytes)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package mock
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Supported values for UsageModel.Type.
+const (
+	usageConstant   = "constant"
+	usageRandomWalk = "randomWalk"
+	usageSine       = "sine"
+	usageStep       = "step"
+	usageLeak       = "leak"
+	usageNoise      = "noise"
+)
+
+// Defaults of the usage models.
+const (
+	defaultSinePeriod = 24 * time.Hour
+	defaultLeakPer    = time.Hour
+	// walkSteps is the number of steps a random walk takes by default to cross its range,
+	// one per second.
+	walkSteps = 20
+	// idleCPU and idleMemory bound the default usage of containers with neither a request
+	// nor a limit.
+	idleCPU    = 100e6
+	idleMemory = 128 << 20
+)
+
+// defaultUsageModel is followed by the containers whose usage is not configured: a random
+// walk between their request and their limit.
+var defaultUsageModel = UsageModel{Type: usageRandomWalk}
+
+// UsageModel describes how the CPU or memory usage of a container changes over time. Its
+// quantities are CPU quantities for CPU models and memory quantities for memory ones:
+//
+//	constant:   {type: constant, value: 250m}
+//	randomWalk: {type: randomWalk, min: 100m, max: "1", step: 50m}
+//	sine:       {type: sine, min: 100m, max: "1", period: 24h, peak: 14h}
+//	step:       {type: step, value: 100m, steps: [{after: 5m, value: 800m}, {after: 10m, value: 200m}]}
+//	leak:       {type: leak, value: 200Mi, rate: 50Mi, per: 1h}
+//	noise:      {type: noise, min: 100m, max: "1"}
+//
+// Min and Max default to the request and the limit of the container, the limit falling
+// back to the allocatable of the node. Random walks start at value, halfway between min and
+// max by default, and move by step (the standard deviation of their moves over a second, a
+// twentieth of their range by default) without leaving it. Sine waves go from max down to
+// min and back every period, peaking at peak after the start of each period counted from
+// the Unix epoch, so daily cycles peak at the same time of day UTC. Steps and leaks follow
+// the time since the container started: steps hold value until the first step is reached,
+// and leaks start at value, min by default, and grow by rate every per until they reach max.
+// Noise is a new random value between min and max at every sample.
+type UsageModel struct {
+	Type   string        `yaml:"type,omitempty"`
+	Value  string        `yaml:"value,omitempty"`
+	Min    string        `yaml:"min,omitempty"`
+	Max    string        `yaml:"max,omitempty"`
+	Step   string        `yaml:"step,omitempty"`
+	Period time.Duration `yaml:"period,omitempty"`
+	Peak   time.Duration `yaml:"peak,omitempty"`
+	Steps  []UsageStep   `yaml:"steps,omitempty"`
+	Rate   string        `yaml:"rate,omitempty"`
+	Per    time.Duration `yaml:"per,omitempty"`
+
+	value, min, max, step, rate *float64
+}
+
+// UsageStep is the usage of a container from After it started on.
+type UsageStep struct {
+	After time.Duration `yaml:"after"`
+	Value string        `yaml:"value"`
+
+	value float64
+}
+
+// IsZero reports whether the model has been left unset.
+func (m UsageModel) IsZero() bool {
+	return m.Type == ""
+}
+
+// compile validates the model and parses its quantities into values of the given scale.
+func (m *UsageModel) compile(scale resource.Scale) error {
+	quantity := func(name, s string) (*float64, error) {
+		v, err := parseUsage(s, scale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, s)
+		}
+		if v == nil {
+			return nil, nil
+		}
+		f := float64(*v)
+		return &f, nil
+	}
+	var err error
+	if m.value, err = quantity("value", m.Value); err != nil {
+		return err
+	}
+	if m.min, err = quantity("min", m.Min); err != nil {
+		return err
+	}
+	if m.max, err = quantity("max", m.Max); err != nil {
+		return err
+	}
+	if m.step, err = quantity("step", m.Step); err != nil {
+		return err
+	}
+	if m.rate, err = quantity("rate", m.Rate); err != nil {
+		return err
+	}
+	if m.min != nil && m.max != nil && *m.min > *m.max {
+		return fmt.Errorf("invalid bounds [%s, %s]", m.Min, m.Max)
+	}
+	if m.Period < 0 || m.Peak < 0 || m.Per < 0 {
+		return fmt.Errorf("negative duration")
+	}
+	steps := make([]UsageStep, len(m.Steps))
+	for i, s := range m.Steps {
+		v, err := quantity("step value", s.Value)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return fmt.Errorf("step %d requires a value", i)
+		}
+		if i > 0 && s.After <= steps[i-1].After {
+			return fmt.Errorf("steps must be in increasing order of after")
+		}
+		s.value = *v
+		steps[i] = s
+	}
+	m.Steps = steps
+
+	switch m.Type {
+	case "", usageRandomWalk, usageSine, usageNoise:
+	case usageConstant:
+		if m.value == nil {
+			return fmt.Errorf("constant model requires value")
+		}
+	case usageStep:
+		if len(m.Steps) == 0 {
+			return fmt.Errorf("step model requires steps")
+		}
+	case usageLeak:
+		if m.rate == nil {
+			return fmt.Errorf("leak model requires rate")
+		}
+	default:
+		return fmt.Errorf("unknown usage model type %q", m.Type)
+	}
+	return nil
+}
+
+// bounds returns the bounds of the model for a container, which default to the container's
+// request and limit of the resource.
+func (m UsageModel) bounds(c *v1.Container, name v1.ResourceName, allocatable v1.ResourceList) (min, max float64) {
+	if m.min != nil {
+		min = *m.min
+	} else if q, ok := c.Resources.Requests[name]; ok {
+		min = scaledValue(q, name)
+	}
+	if m.max != nil {
+		max = *m.max
+	} else if q, ok := c.Resources.Limits[name]; ok {
+		max = scaledValue(q, name)
+	} else {
+		max = scaledValue(allocatable[name], name)
+	}
+	return min, math.Max(min, max)
+}
+
+// defaultBounds returns the bounds of the default usage of a container: its request and
+// its limit of the resource, up to its request without a limit, and idle values without
+// either.
+func defaultBounds(c *v1.Container, name v1.ResourceName) (min, max float64) {
+	request, hasRequest := c.Resources.Requests[name]
+	limit, hasLimit := c.Resources.Limits[name]
+	switch {
+	case hasLimit:
+		max = scaledValue(limit, name)
+	case hasRequest:
+		max = scaledValue(request, name)
+	case name == v1.ResourceCPU:
+		max = idleCPU
+	default:
+		max = idleMemory
+	}
+	if hasRequest {
+		min = scaledValue(request, name)
+	}
+	return min, math.Max(min, max)
+}
+
+// scaledValue returns a quantity in nanocores for CPU and in bytes otherwise.
+func scaledValue(q resource.Quantity, name v1.ResourceName) float64 {
+	if name == v1.ResourceCPU {
+		return float64(q.ScaledValue(resource.Nano))
+	}
+	return float64(q.Value())
+}
+
+// usageWalk is where a random walk is.
+type usageWalk struct {
+	value   float64
+	started bool
+}
+
+// sample returns the usage at now of a container that started at started and was last
+// sampled at last, between min and max.
+func (m UsageModel) sample(walk *usageWalk, started, last, now time.Time, min, max float64) float64 {
+	clamp := func(v float64) float64 {
+		return math.Min(max, math.Max(min, v))
+	}
+	start := func(def float64) float64 {
+		if m.value != nil {
+			return *m.value
+		}
+		return def
+	}
+	switch m.Type {
+	case usageConstant:
+		return *m.value
+	case usageRandomWalk:
+		if !walk.started {
+			walk.value, walk.started = clamp(start((min+max)/2)), true
+			return walk.value
+		}
+		step := (max - min) / walkSteps
+		if m.step != nil {
+			step = *m.step
+		}
+		if dt := now.Sub(last).Seconds(); dt > 0 {
+			walk.value = clamp(walk.value + rand.NormFloat64()*step*math.Sqrt(dt))
+		}
+		return walk.value
+	case usageSine:
+		period := m.Period
+		if period == 0 {
+			period = defaultSinePeriod
+		}
+		phase := float64((now.UnixNano()-int64(m.Peak))%int64(period)) / float64(period)
+		return min + (max-min)*(1+math.Cos(2*math.Pi*phase))/2
+	case usageStep:
+		v := start(min)
+		for _, s := range m.Steps {
+			if now.Sub(started) < s.After {
+				break
+			}
+			v = s.value
+		}
+		return v
+	case usageLeak:
+		per := m.Per
+		if per == 0 {
+			per = defaultLeakPer
+		}
+		return math.Min(max, start(min)+*m.rate*float64(now.Sub(started))/float64(per))
+	case usageNoise:
+		return min + rand.Float64()*(max-min)
+	}
+	return 0
+}
+
+// cpuCounter adds up the CPU time used by a container at the rates it is sampled at.
+type cpuCounter struct {
+	coreNanoSeconds uint64
+	sampled         time.Time
+}
+
+// add accounts for the time since the last sample at the rate of nanoCores and returns
+// the CPU time used so far.
+func (c *cpuCounter) add(nanoCores uint64, now time.Time) uint64 {
+	if now.After(c.sampled) {
+		if !c.sampled.IsZero() {
+			c.coreNanoSeconds += uint64(float64(nanoCores) * now.Sub(c.sampled).Seconds())
+		}
+		c.sampled = now
+	}
+	return c.coreNanoSeconds
+}
@@ -0,0 +1,166 @@
+package mock
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestUsageModelSample(t *testing.T) {
+	container := &v1.Container{Resources: v1.ResourceRequirements{
+		Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+		Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+	}}
+	allocatable := v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(m UsageModel, walk *usageWalk, last, now time.Time) float64 {
+		t.Helper()
+		if err := m.compile(resource.Nano); err != nil {
+			t.Fatal(err)
+		}
+		min, max := m.bounds(container, v1.ResourceCPU, allocatable)
+		return m.sample(walk, started, last, now, min, max)
+	}
+
+	// Random walks stay between the request and the limit, one small move at a time.
+	walk := &usageWalk{}
+	last := started
+	prev := sample(UsageModel{Type: usageRandomWalk}, walk, last, last)
+	if prev != 3e8 {
+		t.Fatalf("expected the walk to start halfway, got %v", prev)
+	}
+	for i := 1; i <= 1000; i++ {
+		now := started.Add(time.Duration(i) * time.Second)
+		v := sample(UsageModel{Type: usageRandomWalk, Step: "10m"}, walk, last, now)
+		if v < 1e8 || v > 5e8 || math.Abs(v-prev) > 1e8 {
+			t.Fatalf("sample %v out of bounds or too far from %v", v, prev)
+		}
+		prev, last = v, now
+	}
+
+	// Sine waves peak at peak and bottom out half a period later.
+	sine := UsageModel{Type: usageSine, Peak: 14 * time.Hour}
+	if v := sample(sine, nil, started, started.Add(14*time.Hour)); v != 5e8 {
+		t.Fatalf("expected the peak at the limit, got %v", v)
+	}
+	if v := sample(sine, nil, started, started.Add(26*time.Hour)); math.Abs(v-1e8) > 1 {
+		t.Fatalf("expected the trough at the request, got %v", v)
+	}
+
+	// Steps and leaks follow the time since the container started.
+	step := UsageModel{Type: usageStep, Value: "200m", Steps: []UsageStep{{After: time.Minute, Value: "1"}, {After: 2 * time.Minute, Value: "50m"}}}
+	for elapsed, want := range map[time.Duration]float64{0: 2e8, time.Minute: 1e9, 3 * time.Minute: 5e7} {
+		if v := sample(step, nil, started, started.Add(elapsed)); v != want {
+			t.Fatalf("expected %v after %v, got %v", want, elapsed, v)
+		}
+	}
+	leak := UsageModel{Type: usageLeak, Rate: "100m", Per: time.Minute}
+	for elapsed, want := range map[time.Duration]float64{0: 1e8, 90 * time.Second: 2.5e8, time.Hour: 5e8} {
+		if v := sample(leak, nil, started, started.Add(elapsed)); v != want {
+			t.Fatalf("expected %v after %v, got %v", want, elapsed, v)
+		}
+	}
+
+	// Noise jumps around between min and max.
+	for i := 0; i < 100; i++ {
+		if v := sample(UsageModel{Type: usageNoise}, nil, started, started); v < 1e8 || v > 5e8 {
+			t.Fatalf("noise %v out of bounds", v)
+		}
+	}
+
+	for _, m := range []UsageModel{
+		{Type: usageConstant},
+		{Type: usageStep},
+		{Type: usageLeak},
+		{Type: usageRandomWalk, Min: "1", Max: "500m"},
+		{Type: usageStep, Steps: []UsageStep{{After: time.Minute, Value: "1"}, {After: time.Second, Value: "2"}}},
+		{Type: "bursty"},
+	} {
+		if err := m.compile(resource.Nano); err == nil {
+			t.Fatalf("expected %+v to be rejected", m)
+		}
+	}
+}
+
+func TestStatsSummaryUsage(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{Behavior: Behavior{Usage: UsageConfig{
+		CPUModel:    UsageModel{Type: usageConstant, Value: "500m"},
+		MemoryModel: UsageModel{Type: usageLeak, Value: "100Mi", Rate: "1Gi", Per: time.Second, Max: "200Mi"},
+	}}})
+	defer stopPods(p)
+	if err := p.CreatePod(context.Background(), newTestPod("web", "app")); err != nil {
+		t.Fatal(err)
+	}
+	waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == "web" && pod.Status.Phase == v1.PodRunning })
+
+	first, err := p.GetStatsSummary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	second, err := p.GetStatsSummary(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The CPU time adds up the usage between the scrapes.
+	before, after := first.Pods[0].Containers[0], second.Pods[0].Containers[0]
+	elapsed := after.CPU.Time.Sub(before.CPU.Time.Time).Seconds()
+	used := float64(*after.CPU.UsageCoreNanoSeconds - *before.CPU.UsageCoreNanoSeconds)
+	if math.Abs(used-5e8*elapsed) > 1e6 || *after.CPU.UsageNanoCores != 5e8 {
+		t.Fatalf("expected 500m over %vs, got %v core nanoseconds", elapsed, used)
+	}
+	if *second.Pods[0].CPU.UsageCoreNanoSeconds != *after.CPU.UsageCoreNanoSeconds {
+		t.Fatalf("expected the pod to add up its containers")
+	}
+	if *second.Node.CPU.UsageCoreNanoSeconds < *after.CPU.UsageCoreNanoSeconds || *second.Node.CPU.UsageNanoCores != 5e8 {
+		t.Fatalf("expected the node to add up its pods, got %+v", second.Node.CPU)
+	}
+	if memory := *after.Memory.WorkingSetBytes; memory != 200<<20 {
+		t.Fatalf("expected the leak to stop at its max, got %d", memory)
+	}
+}
+
+func TestDefaultUsage(t *testing.T) {
+	p, ch := newTestProvider(t, MockConfig{})
+	defer stopPods(p)
+	web := newTestPod("web", "app")
+	web.Spec.Containers[0].Resources = v1.ResourceRequirements{
+		Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m"), v1.ResourceMemory: resource.MustParse("64Mi")},
+		Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("200m"), v1.ResourceMemory: resource.MustParse("128Mi")},
+	}
+	for _, pod := range []*v1.Pod{web, newTestPod("idle", "app")} {
+		if err := p.CreatePod(context.Background(), pod); err != nil {
+			t.Fatal(err)
+		}
+		name := pod.Name
+		waitForPod(t, ch, func(pod *v1.Pod) bool { return pod.Name == name && pod.Status.Phase == v1.PodRunning })
+	}
+
+	// Without a usage configured, containers stay between their request and their limit,
+	// or within idle values when they have neither.
+	bounds := map[string][4]uint64{
+		"web":  {1e8, 2e8, 64 << 20, 128 << 20},
+		"idle": {0, idleCPU, 0, idleMemory},
+	}
+	for i := 0; i < 5; i++ {
+		summary, err := p.GetStatsSummary(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pod := range summary.Pods {
+			b, c := bounds[pod.PodRef.Name], pod.Containers[0]
+			if cpu := *c.CPU.UsageNanoCores; cpu < b[0] || cpu > b[1] {
+				t.Fatalf("expected the CPU of %s between %d and %d, got %d", pod.PodRef.Name, b[0], b[1], cpu)
+			}
+			if memory := *c.Memory.WorkingSetBytes; memory < b[2] || memory > b[3] {
+				t.Fatalf("expected the memory of %s between %d and %d, got %d", pod.PodRef.Name, b[2], b[3], memory)
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/virtual-kubelet/virtual-kubelet/node"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
@@ -34,3 +35,63 @@ type Provider interface {
 type PodMetricsProvider interface {
 	GetStatsSummary(context.Context) (*stats.Summary, error)
 }
+
+// NodeConditionsProvider is an optional interface that providers can implement to let the
+// node conditions be read and changed at runtime.
+type NodeConditionsProvider interface {
+	GetNodeConditions(context.Context) []v1.NodeCondition
+	SetNodeCondition(context.Context, v1.NodeCondition) error
+}
+
+// NodeStatusProvider is an optional interface that providers can implement to let the
+// node resources and the health of the kubelet be changed at runtime.
+type NodeStatusProvider interface {
+	SetNodeResources(ctx context.Context, capacity, allocatable v1.ResourceList) error
+	SetPingFailure(ctx context.Context, message string)
+}
+
+// PodAdopter is an optional interface that providers can implement to take over the pods
+// already bound to the node when mocklet starts, instead of having them created again.
+type PodAdopter interface {
+	AdoptPods(context.Context) error
+}
+
+// NodeWatcher is an optional interface that providers can implement to follow the changes
+// the control plane makes to the node after it is registered, such as the pod CIDR assigned
+// by the node IPAM controller.
+type NodeWatcher interface {
+	UpdateNode(context.Context, *v1.Node)
+}
+
+// NamespaceReader is an optional interface that providers can implement to read the
+// namespaces of their pods, e.g. to select pods by the labels of their namespace. Since it
+// watches every namespace of the cluster, the namespace informer only runs for providers
+// that need it.
+type NamespaceReader interface {
+	ReadsNamespaces() bool
+}
+
+// ContainerLogOpts are the options of a container logs request, as the kubelet API takes
+// them.
+type ContainerLogOpts struct {
+	// Tail is the number of lines returned from the end of the logs. Negative returns
+	// every line.
+	Tail int
+	// LimitBytes, when positive, is the number of bytes after which the logs are cut.
+	LimitBytes int
+	// Timestamps prefixes each line with the RFC3339 time it was written at.
+	Timestamps bool
+	// Follow streams the lines as they are written, until the container stops.
+	Follow bool
+	// Previous returns the logs of the previous run of the container.
+	Previous bool
+	// SinceSeconds and SinceTime, when set, only return the lines written since then.
+	SinceSeconds int
+	SinceTime    time.Time
+}
+
+// ContainerLogsProvider is an optional interface that providers can implement to serve
+// container logs with every option of the kubelet API, which GetContainerLogs predates.
+type ContainerLogsProvider interface {
+	GetContainerLogsWithOpts(ctx context.Context, namespace, podName, containerName string, opts ContainerLogOpts) (io.ReadCloser, error)
+}
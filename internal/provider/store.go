@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"k8s.io/client-go/tools/record"
 )
 
 // Store is used for registering/fetching providers
@@ -69,6 +70,8 @@ type InitConfig struct {
 	DaemonPort        int32
 	KubeClusterDomain string
 	ResourceManager   *manager.ResourceManager
+	// EventRecorder records the events the kubelet would record about pods.
+	EventRecorder record.EventRecorder
 }
 
 type InitFunc func(InitConfig) (Provider, error)
@@ -16,6 +16,7 @@ package manager
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 
@@ -23,25 +24,34 @@ import (
 )
 
 // ResourceManager acts as a passthrough to a cache (lister) for pods assigned to the current node.
-// It is also a passthrough to a cache (lister) for Kubernetes secrets and config maps.
+// It is also a passthrough to a cache (lister) for Kubernetes secrets, config maps, persistent volume claims and namespaces.
 type ResourceManager struct {
 	podLister       corev1listers.PodLister
 	secretLister    corev1listers.SecretLister
 	configMapLister corev1listers.ConfigMapLister
 	serviceLister   corev1listers.ServiceLister
+	pvcLister       corev1listers.PersistentVolumeClaimLister
+	namespaceLister corev1listers.NamespaceLister
 }
 
 // NewResourceManager returns a ResourceManager with the internal maps initialized.
-func NewResourceManager(podLister corev1listers.PodLister, secretLister corev1listers.SecretLister, configMapLister corev1listers.ConfigMapLister, serviceLister corev1listers.ServiceLister) (*ResourceManager, error) {
+func NewResourceManager(podLister corev1listers.PodLister, secretLister corev1listers.SecretLister, configMapLister corev1listers.ConfigMapLister, serviceLister corev1listers.ServiceLister, pvcLister corev1listers.PersistentVolumeClaimLister) (*ResourceManager, error) {
 	rm := ResourceManager{
 		podLister:       podLister,
 		secretLister:    secretLister,
 		configMapLister: configMapLister,
 		serviceLister:   serviceLister,
+		pvcLister:       pvcLister,
 	}
 	return &rm, nil
 }
 
+// SetNamespaceLister sets the cache namespaces are retrieved from. Namespaces are only
+// cached when they are read, as that watches every namespace of the cluster.
+func (rm *ResourceManager) SetNamespaceLister(namespaceLister corev1listers.NamespaceLister) {
+	rm.namespaceLister = namespaceLister
+}
+
 // GetPods returns a list of all known pods assigned to this virtual node.
 func (rm *ResourceManager) GetPods() []*v1.Pod {
 	l, err := rm.podLister.List(labels.Everything())
@@ -52,6 +62,11 @@ func (rm *ResourceManager) GetPods() []*v1.Pod {
 	return make([]*v1.Pod, 0)
 }
 
+// GetPod retrieves the specified pod from the cache.
+func (rm *ResourceManager) GetPod(name, namespace string) (*v1.Pod, error) {
+	return rm.podLister.Pods(namespace).Get(name)
+}
+
 // GetConfigMap retrieves the specified config map from the cache.
 func (rm *ResourceManager) GetConfigMap(name, namespace string) (*v1.ConfigMap, error) {
 	return rm.configMapLister.ConfigMaps(namespace).Get(name)
@@ -62,6 +77,19 @@ func (rm *ResourceManager) GetSecret(name, namespace string) (*v1.Secret, error)
 	return rm.secretLister.Secrets(namespace).Get(name)
 }
 
+// GetPersistentVolumeClaim retrieves the specified persistent volume claim from the cache.
+func (rm *ResourceManager) GetPersistentVolumeClaim(name, namespace string) (*v1.PersistentVolumeClaim, error) {
+	return rm.pvcLister.PersistentVolumeClaims(namespace).Get(name)
+}
+
+// GetNamespace retrieves the specified namespace from the cache, if namespaces are cached.
+func (rm *ResourceManager) GetNamespace(name string) (*v1.Namespace, error) {
+	if rm.namespaceLister == nil {
+		return nil, errors.NewNotFound(v1.Resource("namespaces"), name)
+	}
+	return rm.namespaceLister.Get(name)
+}
+
 // ListServices retrieves the list of services from Kubernetes.
 func (rm *ResourceManager) ListServices() ([]*v1.Service, error) {
 	return rm.serviceLister.List(labels.Everything())
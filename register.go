@@ -13,6 +13,9 @@ func registerMock(s *provider.Store) {
 			cfg.OperatingSystem,
 			cfg.InternalIP,
 			cfg.DaemonPort,
+			cfg.KubeClusterDomain,
+			cfg.ResourceManager,
+			cfg.EventRecorder,
 		)
 	})
 }